@@ -0,0 +1,186 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync/atomic"
+)
+
+// Authorization modes of a bucket
+const (
+	AuthModePublic        = "public"
+	AuthModeAuthenticated = "authenticated"
+	AuthModeClaims        = "claims"
+)
+
+// DefaultAccessClaim is the JWT claim listing the buckets and prefixes a token may read
+const DefaultAccessClaim = "cdn_access"
+
+// AuthPolicy controls who may read a bucket
+type AuthPolicy struct {
+	// Mode is public (default), authenticated (any valid token) or claims
+	Mode string `json:"mode"`
+	// Claim names the JWT claim checked in claims mode. It holds "bucket" or "bucket/prefix"
+	// entries, as an array or a space separated string; "*" grants every bucket.
+	Claim string `json:"claim"`
+}
+
+// AuthPolicy returns the policy of bucket, the "*" entry applies to buckets without their own
+func (config *EnvConfig) AuthPolicy(bucket string) AuthPolicy {
+	policy, ok := config.Auth.Policies[bucket]
+	if !ok {
+		policy, ok = config.Auth.Policies["*"]
+	}
+	if !ok || policy.Mode == "" {
+		policy.Mode = AuthModePublic
+	}
+	if policy.Claim == "" {
+		policy.Claim = DefaultAccessClaim
+	}
+	return policy
+}
+
+// AccessAllowed reports whether one of the claim entries grants access to key in bucket
+func AccessAllowed(entries []string, bucket, key string) bool {
+	for _, entry := range entries {
+		if entry == "*" || entry == bucket {
+			return true
+		}
+		entryBucket, prefix, ok := strings.Cut(entry, "/")
+		if ok && entryBucket == bucket && keyUnderPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyUnderPrefix matches whole path segments, so "tenants/acme" grants "tenants/acme/..." but not "tenants/acme-evil/..."
+func keyUnderPrefix(key, prefix string) bool {
+	if prefix == "" || key == prefix {
+		return true
+	}
+	return strings.HasPrefix(key, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// KeySet holds the JWT verification keys, replaced atomically when the key file is reloaded
+type KeySet struct {
+	keys atomic.Pointer[map[string]interface{}]
+}
+
+// Load parses a JWKS document ({"keys": [...]}) with RSA, EC and oct keys. Any other content is
+// used as a single HMAC secret. Invalid input leaves the current keys active.
+func (ks *KeySet) Load(data []byte) error {
+	keys := map[string]interface{}{}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err == nil && jwks.Keys != nil {
+		for i, jwk := range jwks.Keys {
+			key, err := jwk.publicKey()
+			if err != nil {
+				return fmt.Errorf("key %d (%s): %w", i, jwk.Kid, err)
+			}
+			keys[jwk.Kid] = key
+		}
+	} else {
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return fmt.Errorf("key file is empty")
+		}
+		keys[""] = []byte(secret)
+	}
+
+	ks.keys.Store(&keys)
+	return nil
+}
+
+// Lookup returns the key with the given kid. A token without kid matches the only configured key.
+func (ks *KeySet) Lookup(kid string) (interface{}, bool) {
+	keys := ks.keys.Load()
+	if keys == nil {
+		return nil, false
+	}
+	if key, ok := (*keys)[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(*keys) == 1 {
+		for _, key := range *keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// jsonWebKey is the subset of RFC 7517 needed to verify signatures
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct
+	K string `json:"k"`
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.K, "="))
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestAccessAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		bucket  string
+		key     string
+		want    bool
+	}{
+		{"no entries", nil, "tenants", "acme/a.txt", false},
+		{"wildcard", []string{"*"}, "tenants", "acme/a.txt", true},
+		{"whole bucket", []string{"tenants"}, "tenants", "acme/a.txt", true},
+		{"bucket with trailing slash", []string{"tenants/"}, "tenants", "acme/a.txt", true},
+		{"other bucket", []string{"reports"}, "tenants", "acme/a.txt", false},
+		{"bucket name is not a prefix", []string{"tenant"}, "tenants", "acme/a.txt", false},
+		{"key below prefix", []string{"tenants/acme"}, "tenants", "acme/a.txt", true},
+		{"nested key below prefix", []string{"tenants/acme"}, "tenants", "acme/2024/01/a.txt", true},
+		{"key equal to prefix", []string{"tenants/acme"}, "tenants", "acme", true},
+		{"prefix with trailing slash", []string{"tenants/acme/"}, "tenants", "acme/a.txt", true},
+		{"sibling sharing the prefix", []string{"tenants/acme"}, "tenants", "acme-evil/a.txt", false},
+		{"sibling sharing the prefix without separator", []string{"tenants/acme"}, "tenants", "acme2/a.txt", false},
+		{"key above prefix", []string{"tenants/acme/private"}, "tenants", "acme/a.txt", false},
+		{"prefix in another bucket", []string{"reports/acme"}, "tenants", "acme/a.txt", false},
+		{"any entry matches", []string{"reports", "tenants/acme"}, "tenants", "acme/a.txt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AccessAllowed(tt.entries, tt.bucket, tt.key); got != tt.want {
+				t.Errorf("AccessAllowed(%q, %q, %q) = %t, want %t", tt.entries, tt.bucket, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthPolicy(t *testing.T) {
+	config := &EnvConfig{}
+	config.Auth.Policies = map[string]AuthPolicy{
+		"*":       {Mode: AuthModeAuthenticated},
+		"tenants": {Mode: AuthModeClaims, Claim: "tenant_paths"},
+		"assets":  {},
+	}
+
+	tests := []struct {
+		bucket string
+		want   AuthPolicy
+	}{
+		{"tenants", AuthPolicy{Mode: AuthModeClaims, Claim: "tenant_paths"}},
+		{"reports", AuthPolicy{Mode: AuthModeAuthenticated, Claim: DefaultAccessClaim}},
+		{"assets", AuthPolicy{Mode: AuthModePublic, Claim: DefaultAccessClaim}},
+	}
+
+	for _, tt := range tests {
+		if got := config.AuthPolicy(tt.bucket); got != tt.want {
+			t.Errorf("AuthPolicy(%q) = %+v, want %+v", tt.bucket, got, tt.want)
+		}
+	}
+
+	if got := (&EnvConfig{}).AuthPolicy("any"); got.Mode != AuthModePublic {
+		t.Errorf("AuthPolicy without policies = %+v, want public", got)
+	}
+}
+
+func TestKeySetLoadJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := `{"keys": [
+		{"kty": "RSA", "kid": "rsa", "n": "` + b64(rsaKey.N.Bytes()) + `", "e": "` + b64(big.NewInt(int64(rsaKey.E)).Bytes()) + `"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "` + b64(ecKey.X.Bytes()) + `", "y": "` + b64(ecKey.Y.Bytes()) + `"},
+		{"kty": "oct", "kid": "hmac", "k": "` + b64([]byte("shared-secret")) + `"}
+	]}`
+
+	var ks KeySet
+	if err := ks.Load([]byte(jwks)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if key, ok := ks.Lookup("rsa"); !ok || !key.(*rsa.PublicKey).Equal(&rsaKey.PublicKey) {
+		t.Errorf("Lookup(rsa) = %v, %t", key, ok)
+	}
+	if key, ok := ks.Lookup("ec"); !ok || !key.(*ecdsa.PublicKey).Equal(&ecKey.PublicKey) {
+		t.Errorf("Lookup(ec) = %v, %t", key, ok)
+	}
+	if key, ok := ks.Lookup("hmac"); !ok || string(key.([]byte)) != "shared-secret" {
+		t.Errorf("Lookup(hmac) = %v, %t", key, ok)
+	}
+	if _, ok := ks.Lookup("unknown"); ok {
+		t.Error("Lookup(unknown) found a key")
+	}
+	if _, ok := ks.Lookup(""); ok {
+		t.Error("token without kid matched one of several keys")
+	}
+}
+
+func TestKeySetLoadSecret(t *testing.T) {
+	var ks KeySet
+	if err := ks.Load([]byte("  raw-secret\n")); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if key, ok := ks.Lookup(""); !ok || string(key.([]byte)) != "raw-secret" {
+		t.Errorf("Lookup(\"\") = %v, %t, want the raw secret", key, ok)
+	}
+	if key, ok := ks.Lookup("any"); ok {
+		t.Errorf("Lookup(any) = %v, a kid must match exactly", key)
+	}
+}
+
+func TestKeySetLoadKeepsPreviousOnError(t *testing.T) {
+	var ks KeySet
+	if _, ok := ks.Lookup(""); ok {
+		t.Fatal("empty KeySet returned a key")
+	}
+	if err := ks.Load([]byte("first-secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty file", "  \n"},
+		{"unsupported key type", `{"keys": [{"kty": "OKP", "kid": "ed"}]}`},
+		{"unsupported curve", `{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-224", "x": "AQ", "y": "AQ"}]}`},
+		{"invalid RSA modulus", `{"keys": [{"kty": "RSA", "kid": "rsa", "n": "", "e": "AQAB"}]}`},
+		{"invalid oct key", `{"keys": [{"kty": "oct", "kid": "hmac", "k": "***"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ks.Load([]byte(tt.data)); err == nil {
+				t.Fatal("Load succeeded, want error")
+			}
+			if key, ok := ks.Lookup(""); !ok || string(key.([]byte)) != "first-secret" {
+				t.Errorf("keys after failed reload = %v, %t, want the previous secret", key, ok)
+			}
+		})
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
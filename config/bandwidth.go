@@ -0,0 +1,44 @@
+package config
+
+// BandwidthLimit is a token bucket of bytes per second with a burst allowance
+type BandwidthLimit struct {
+	BytesPerSecond int64 `json:"bytes_per_second"`
+	// Burst is how many bytes may go out at full speed before shaping starts, one second's worth by default
+	Burst int64 `json:"burst"`
+}
+
+// BandwidthConfig shapes streamed responses. Every applicable limit is enforced at once.
+type BandwidthConfig struct {
+	Connection *BandwidthLimit `json:"connection"`
+	// IP is shared by all connections of a client IP on this replica
+	IP *BandwidthLimit `json:"ip"`
+	// Buckets are shared by all connections reading the bucket, "*" gives every other bucket its own limit
+	Buckets map[string]BandwidthLimit `json:"buckets"`
+	// Tiers replace the connection and IP limits for signed URLs carrying tier=<name>;
+	// a zero rate means unlimited
+	Tiers map[string]BandwidthLimit `json:"tiers"`
+}
+
+// IsEnabled reports whether the limit shapes traffic
+func (limit *BandwidthLimit) IsEnabled() bool {
+	return limit != nil && limit.BytesPerSecond > 0
+}
+
+// BurstBytes returns the burst allowance, defaulting to one second of traffic
+func (limit *BandwidthLimit) BurstBytes() int {
+	if limit.Burst > 0 {
+		return int(limit.Burst)
+	}
+	return int(limit.BytesPerSecond)
+}
+
+// BucketBandwidth returns the bandwidth limit of bucket, or nil when it is not shaped
+func (config *EnvConfig) BucketBandwidth(bucket string) *BandwidthLimit {
+	if limit, ok := config.Bandwidth.Buckets[bucket]; ok {
+		return &limit
+	}
+	if limit, ok := config.Bandwidth.Buckets["*"]; ok {
+		return &limit
+	}
+	return nil
+}
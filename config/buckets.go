@@ -0,0 +1,35 @@
+package config
+
+// ResolveBucket maps the bucket name used in URLs to the storage bucket. Aliases from BUCKET_ALIASES
+// come first, then EXPOSED_BUCKETS; with neither configured every bucket is served as before.
+// Returns false for buckets that must not be exposed.
+func (config *EnvConfig) ResolveBucket(name string) (string, bool) {
+	if bucket, ok := config.Buckets.Aliases[name]; ok {
+		return bucket, true
+	}
+
+	if len(config.Buckets.Exposed) == 0 && len(config.Buckets.Aliases) == 0 {
+		return name, true
+	}
+
+	for _, exposed := range config.Buckets.Exposed {
+		if exposed == name {
+			return name, true
+		}
+	}
+
+	// "*" exposes every bucket under its own name, except those hidden behind an alias
+	if containsOrWildcard(config.Buckets.Exposed, "*") && !config.isAliasTarget(name) {
+		return name, true
+	}
+	return "", false
+}
+
+func (config *EnvConfig) isAliasTarget(bucket string) bool {
+	for _, target := range config.Buckets.Aliases {
+		if target == bucket {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package config
+
+import "testing"
+
+func TestResolveBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		exposed []string
+		aliases map[string]string
+		request string
+		want    string
+		wantOK  bool
+	}{
+		{name: "nothing configured serves every bucket", request: "internal", want: "internal", wantOK: true},
+		{name: "exposed bucket", exposed: []string{"media", "docs"}, request: "docs", want: "docs", wantOK: true},
+		{name: "bucket not exposed", exposed: []string{"media"}, request: "internal", wantOK: false},
+		{name: "alias", aliases: map[string]string{"img": "prod-media-2024"}, request: "img", want: "prod-media-2024", wantOK: true},
+		{name: "aliases alone hide other buckets", aliases: map[string]string{"img": "prod-media-2024"}, request: "internal", wantOK: false},
+		{name: "alias target not reachable by its own name", exposed: []string{"media"}, aliases: map[string]string{"img": "prod-media-2024"}, request: "prod-media-2024", wantOK: false},
+		{name: "alias wins over an exposed bucket of the same name", exposed: []string{"img"}, aliases: map[string]string{"img": "prod-media-2024"}, request: "img", want: "prod-media-2024", wantOK: true},
+		{name: "wildcard exposes every bucket", exposed: []string{"*"}, request: "anything", want: "anything", wantOK: true},
+		{name: "wildcard keeps alias targets hidden", exposed: []string{"*"}, aliases: map[string]string{"img": "prod-media-2024"}, request: "prod-media-2024", wantOK: false},
+		{name: "alias target also listed by name", exposed: []string{"prod-media-2024"}, aliases: map[string]string{"img": "prod-media-2024"}, request: "prod-media-2024", want: "prod-media-2024", wantOK: true},
+		{name: "names are case sensitive", exposed: []string{"media"}, request: "Media", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &EnvConfig{}
+			config.Buckets.Exposed = tt.exposed
+			config.Buckets.Aliases = tt.aliases
+
+			got, ok := config.ResolveBucket(tt.request)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ResolveBucket(%q) = %q, %t, want %q, %t", tt.request, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
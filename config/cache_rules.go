@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// CacheRule overrides caching for objects matched by bucket, key pattern and content type.
+// Empty match fields match everything, the first matching rule wins.
+type CacheRule struct {
+	Name string `json:"name"`
+
+	Bucket string `json:"bucket"`
+	// Prefix matches the start of the key, Pattern is a path.Match glob. A pattern without "/"
+	// is matched against the file name only, so "*.html" applies in every folder.
+	Prefix      string `json:"prefix"`
+	Pattern     string `json:"pattern"`
+	ContentType string `json:"content_type"` // exact type or "image/*"
+
+	// CacheControl is sent to browsers as is, SMaxAge adds s-maxage for shared caches
+	CacheControl string `json:"cache_control"`
+	SMaxAge      *int64 `json:"s_maxage"`
+	// RedisTTL is in seconds, 0 keeps matching objects out of Redis
+	RedisTTL *int64 `json:"redis_ttl"`
+}
+
+// CachePolicy is the resolved caching behaviour of one object
+type CachePolicy struct {
+	RuleName     string
+	CacheControl string
+	// RuleCacheControl is set when CacheControl comes from the matched rule, which then wins over object metadata
+	RuleCacheControl bool
+	RedisTTL         time.Duration
+	SkipRedis        bool
+}
+
+// Matches reports whether the rule applies to the object
+func (rule *CacheRule) Matches(bucket, key, contentType string) bool {
+	if rule.Bucket != "" && rule.Bucket != "*" && rule.Bucket != bucket {
+		return false
+	}
+	if rule.Prefix != "" && !strings.HasPrefix(key, rule.Prefix) {
+		return false
+	}
+	if rule.Pattern != "" {
+		target := key
+		if !strings.Contains(rule.Pattern, "/") {
+			target = path.Base(key)
+		}
+		if matched, err := path.Match(rule.Pattern, target); err != nil || !matched {
+			return false
+		}
+	}
+	if rule.ContentType != "" && !matchContentType(rule.ContentType, contentType) {
+		return false
+	}
+	return true
+}
+
+// CachePolicy resolves the caching behaviour for an object from CACHE_RULES, falling back to CACHE_TIME
+func (config *EnvConfig) CachePolicy(bucket, key, contentType string) CachePolicy {
+	policy := CachePolicy{
+		RedisTTL: time.Second * time.Duration(config.Limit.CacheTime),
+	}
+	if config.Limit.CacheTime > 0 {
+		policy.CacheControl = fmt.Sprintf("public, max-age=%d", config.Limit.CacheTime)
+	}
+
+	for i := range config.CacheRules {
+		rule := &config.CacheRules[i]
+		if !rule.Matches(bucket, key, contentType) {
+			continue
+		}
+
+		policy.RuleName = rule.Name
+		if policy.RuleName == "" {
+			policy.RuleName = fmt.Sprintf("rule-%d", i)
+		}
+		if rule.CacheControl != "" {
+			policy.CacheControl = rule.CacheControl
+			policy.RuleCacheControl = true
+		}
+		if rule.SMaxAge != nil {
+			if policy.CacheControl == "" {
+				policy.CacheControl = "public"
+			}
+			policy.CacheControl += fmt.Sprintf(", s-maxage=%d", *rule.SMaxAge)
+			policy.RuleCacheControl = true
+		}
+		if rule.RedisTTL != nil {
+			policy.RedisTTL = time.Second * time.Duration(*rule.RedisTTL)
+			policy.SkipRedis = *rule.RedisTTL <= 0
+		}
+		break
+	}
+
+	return policy
+}
+
+// matchContentType compares media types ignoring parameters, "type/*" matches the whole family
+func matchContentType(pattern, contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	pattern = strings.ToLower(pattern)
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return mediaType == pattern
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachePolicy(t *testing.T) {
+	sMaxAge, ttl, skip := int64(600), int64(60), int64(0)
+	cfg := &EnvConfig{}
+	cfg.Limit.CacheTime = 3600
+	cfg.CacheRules = []CacheRule{
+		{Name: "assets", Bucket: "web", Prefix: "assets/", CacheControl: "public, max-age=31536000, immutable"},
+		{Name: "html", ContentType: "text/html", RedisTTL: &ttl},
+		{Name: "shared", Pattern: "*.json", SMaxAge: &sMaxAge},
+		{Name: "private", Bucket: "secret", RedisTTL: &skip},
+	}
+
+	tests := []struct {
+		name        string
+		bucket, key string
+		contentType string
+		want        CachePolicy
+	}{
+		{
+			name:   "default from CACHE_TIME",
+			bucket: "web", key: "a.png", contentType: "image/png",
+			want: CachePolicy{CacheControl: "public, max-age=3600", RedisTTL: time.Hour},
+		},
+		{
+			name:   "explicit rule Cache-Control",
+			bucket: "web", key: "assets/app.js", contentType: "text/javascript",
+			want: CachePolicy{RuleName: "assets", CacheControl: "public, max-age=31536000, immutable", RuleCacheControl: true, RedisTTL: time.Hour},
+		},
+		{
+			name:   "rule with only a Redis TTL keeps the default Cache-Control",
+			bucket: "web", key: "index.html", contentType: "text/html; charset=utf-8",
+			want: CachePolicy{RuleName: "html", CacheControl: "public, max-age=3600", RedisTTL: time.Minute},
+		},
+		{
+			name:   "s-maxage is appended",
+			bucket: "api", key: "v1/data.json", contentType: "application/json",
+			want: CachePolicy{RuleName: "shared", CacheControl: "public, max-age=3600, s-maxage=600", RuleCacheControl: true, RedisTTL: time.Hour},
+		},
+		{
+			name:   "zero Redis TTL skips Redis",
+			bucket: "secret", key: "a.png", contentType: "image/png",
+			want: CachePolicy{RuleName: "private", CacheControl: "public, max-age=3600", SkipRedis: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.CachePolicy(tt.bucket, tt.key, tt.contentType); got != tt.want {
+				t.Errorf("CachePolicy(%q, %q, %q) = %+v, want %+v", tt.bucket, tt.key, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+)
+
+// CORSConfig is the CORS policy of a bucket
+type CORSConfig struct {
+	// AllowedOrigins accepts exact origins, "*" and single-label wildcards such as "https://*.example.com"
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"` // "*" echoes the requested headers
+	ExposedHeaders   []string `json:"exposed_headers"`
+	MaxAge           int      `json:"max_age"`
+	AllowCredentials bool     `json:"allow_credentials"`
+}
+
+// CORSPolicy returns the CORS policy of bucket, the "*" entry applies to buckets without their own.
+// Returns nil when CORS is disabled for the bucket.
+func (config *EnvConfig) CORSPolicy(bucket string) *CORSConfig {
+	if cors, ok := config.CORS[bucket]; ok {
+		return &cors
+	}
+	if cors, ok := config.CORS["*"]; ok {
+		return &cors
+	}
+	return nil
+}
+
+// AllowsOrigin reports whether origin matches one of the allowed origins
+func (cors *CORSConfig) AllowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range cors.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				// The wildcard stands for exactly one subdomain label, never for a scheme or port change
+				wildcard := origin[len(prefix) : len(origin)-len(suffix)]
+				if !strings.ContainsAny(wildcard, ".:/") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// AllowsAnyOrigin reports whether the policy uses the bare "*" origin
+func (cors *CORSConfig) AllowsAnyOrigin() bool {
+	for _, allowed := range cors.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod reports whether method may be used cross-origin, GET and HEAD are allowed by default
+func (cors *CORSConfig) AllowsMethod(method string) bool {
+	if len(cors.AllowedMethods) == 0 {
+		return method == "GET" || method == "HEAD"
+	}
+	for _, allowed := range cors.AllowedMethods {
+		if allowed == "*" || strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
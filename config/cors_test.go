@@ -0,0 +1,38 @@
+package config
+
+import "testing"
+
+func TestCORSAllowsOrigin(t *testing.T) {
+	cors := &CORSConfig{AllowedOrigins: []string{"https://app.example.org", "https://*.example.com", "http://localhost:*"}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.org", true},
+		{"HTTPS://APP.EXAMPLE.ORG", true},
+		{"https://other.example.org", false},
+		{"https://cdn.example.com", true},
+		{"https://a.b.example.com", false},
+		{"https://example.com", false},
+		{"https://.example.com", false},
+		{"http://cdn.example.com", false},
+		{"https://evil.com/.example.com", false},
+		{"https://cdn.example.com:8443", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3000/x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := cors.AllowsOrigin(tt.origin); got != tt.want {
+				t.Errorf("AllowsOrigin(%q) = %t, want %t", tt.origin, got, tt.want)
+			}
+		})
+	}
+
+	anyOrigin := &CORSConfig{AllowedOrigins: []string{"*"}}
+	if !anyOrigin.AllowsOrigin("https://anything.net") || !anyOrigin.AllowsAnyOrigin() {
+		t.Error(`"*" should allow every origin`)
+	}
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"encoding/json"
+	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type EnvConfig struct {
@@ -20,11 +23,77 @@ type EnvConfig struct {
 		SecretKey  string
 		BucketName string
 		UseSSL     bool
+
+		// Clients built for caller-supplied credentials are pooled
+		ClientPoolSize int
+		ClientIdleTTL  time.Duration
 	}
 
 	Limit struct {
 		CacheTime int64
-		CacheSize int64
+		// CacheSize is the byte budget of the in-process cache tier, MemoryCacheTTL its TTL in seconds
+		CacheSize      int64
+		MemoryCacheTTL int64
+	}
+
+	// DiskCache keeps objects too large for Redis in a local directory, disabled when Dir is empty
+	DiskCache struct {
+		Dir     string
+		MaxSize int64
+	}
+
+	Network struct {
+		TrustedProxies []string
+		// IPFilter is reloaded in place when IP_FILTER_FILE changes
+		IPFilter *IPFilter
+	}
+
+	Buckets struct {
+		Exposed []string
+		Aliases map[string]string
+	}
+
+	CacheRules []CacheRule
+
+	RateLimits []RateLimitRule
+
+	Bandwidth BandwidthConfig
+
+	CORS map[string]CORSConfig
+
+	VirtualHosts map[string]VirtualHost
+
+	Hotlink map[string]HotlinkPolicy
+
+	// Rewrites is reloaded in place when REWRITE_RULES_FILE changes
+	Rewrites *RewriteRules
+
+	SignedURL struct {
+		Keys                  map[string]string
+		Buckets               []string
+		AllowQueryCredentials bool
+	}
+
+	Auth struct {
+		Policies   map[string]AuthPolicy
+		Keys       *KeySet
+		CookieName string
+		Issuer     string
+		Audience   string
+	}
+
+	Website struct {
+		Buckets      map[string]WebsiteConfig
+		MarkerObject string
+	}
+
+	Listing struct {
+		Buckets  []string
+		MaxLimit int
+	}
+
+	Metadata struct {
+		ExposedHeaders []string
 	}
 
 	Grafana struct {
@@ -66,6 +135,18 @@ func LoadEnvConfig() *EnvConfig {
 	// UseSSL flag - explicit control over SSL usage
 	config.Minio.UseSSL = os.Getenv("MINIO_USE_SSL") == "true"
 
+	// Pool of clients for requests carrying their own credentials
+	clientPoolSize, err := strconv.Atoi(os.Getenv("MINIO_CLIENT_POOL_SIZE"))
+	if err != nil || clientPoolSize <= 0 {
+		clientPoolSize = 64
+	}
+	config.Minio.ClientPoolSize = clientPoolSize
+	clientIdleTTL, err := strconv.Atoi(os.Getenv("MINIO_CLIENT_IDLE_TTL"))
+	if err != nil || clientIdleTTL <= 0 {
+		clientIdleTTL = 600 // 10 minutes
+	}
+	config.Minio.ClientIdleTTL = time.Duration(clientIdleTTL) * time.Second
+
 	// Limit
 	cacheTime, err := strconv.ParseInt(os.Getenv("CACHE_TIME"), 10, 64)
 	if err != nil {
@@ -78,6 +159,139 @@ func LoadEnvConfig() *EnvConfig {
 		cacheSize = 10 * 1024 * 1024 // 10 MB
 	}
 	config.Limit.CacheSize = cacheSize
+	memoryCacheTTL, err := strconv.ParseInt(os.Getenv("MEMORY_CACHE_TTL"), 10, 64)
+	if err != nil || memoryCacheTTL <= 0 {
+		memoryCacheTTL = 60 // 1 minute, capped by the Redis TTL of each entry
+	}
+	config.Limit.MemoryCacheTTL = memoryCacheTTL
+
+	// Objects over 50MB are cached on disk when DISK_CACHE_DIR is set. Mount it on a persistent
+	// volume to keep the cache, and its index, across pod restarts.
+	config.DiskCache.Dir = os.Getenv("DISK_CACHE_DIR")
+	diskCacheSize, err := strconv.ParseInt(os.Getenv("DISK_CACHE_SIZE"), 10, 64)
+	if err != nil || diskCacheSize <= 0 {
+		diskCacheSize = 10 * 1024 * 1024 * 1024 // 10 GB
+	}
+	config.DiskCache.MaxSize = diskCacheSize
+
+	// X-Forwarded-For / X-Real-IP are only honoured from TRUSTED_PROXIES (IPs or CIDRs, e.g. the ingress
+	// pod range); when unset the connection address is the client IP
+	config.Network.TrustedProxies = splitList(os.Getenv("TRUSTED_PROXIES"))
+
+	// CIDR allow/deny lists, global and per bucket. IP_FILTER_FILE is watched and reloaded,
+	// IP_FILTER is read once at startup
+	config.Network.IPFilter = &IPFilter{}
+	if filterFile := os.Getenv("IP_FILTER_FILE"); filterFile != "" {
+		watchFile("IP filter", filterFile, reloadInterval("IP_FILTER_RELOAD_INTERVAL"), config.Network.IPFilter.Load)
+	} else if filter := os.Getenv("IP_FILTER"); filter != "" {
+		if err := config.Network.IPFilter.Load([]byte(filter)); err != nil {
+			log.Printf("Invalid IP_FILTER, ignoring: %v", err)
+		}
+	}
+
+	// Buckets served under /:bucket/*path. BUCKET_ALIASES maps public names to storage buckets,
+	// e.g. {"img": "prod-media-2024"}; EXPOSED_BUCKETS lists buckets served under their own name.
+	// Virtual hosts and rewrite targets use public names, every other per-bucket setting the storage name.
+	config.Buckets.Exposed = splitList(os.Getenv("EXPOSED_BUCKETS"))
+	config.Buckets.Aliases = map[string]string{}
+	loadJSONEnv("BUCKET_ALIASES", &config.Buckets.Aliases)
+
+	// Cache-Control rules per bucket / key pattern / content type, e.g.
+	// [{"name": "hashed-assets", "bucket": "web", "pattern": "assets/*", "cache_control": "public, max-age=31536000, immutable"},
+	//  {"name": "html", "content_type": "text/html", "cache_control": "no-cache", "redis_ttl": 60}]
+	loadJSONEnv("CACHE_RULES", &config.CacheRules)
+
+	// Distributed rate limits shared by all replicas through Redis, e.g.
+	// [{"name": "per-ip", "key": "ip", "requests_per_second": 20, "burst": 50, "bytes_per_second": 10485760},
+	//  {"name": "videos", "key": "bucket", "bucket": "videos", "requests_per_second": 500}]
+	loadJSONEnv("RATE_LIMITS", &config.RateLimits)
+	config.RateLimits = normalizeRateLimits(config.RateLimits)
+
+	// Bandwidth shaping of streamed files and ranges, e.g.
+	// {"connection": {"bytes_per_second": 2097152, "burst": 8388608}, "ip": {"bytes_per_second": 4194304},
+	//  "buckets": {"videos": {"bytes_per_second": 104857600}}, "tiers": {"premium": {"bytes_per_second": 0}}}
+	loadJSONEnv("BANDWIDTH_LIMITS", &config.Bandwidth)
+
+	// CORS policies per bucket, "*" applies to every other bucket, e.g.
+	// {"fonts": {"allowed_origins": ["https://*.example.com"], "exposed_headers": ["ETag"], "max_age": 3600}}
+	config.CORS = map[string]CORSConfig{}
+	loadJSONEnv("CORS_CONFIG", &config.CORS)
+
+	// Custom domains served from a bucket without the bucket in the URL, e.g.
+	// {"assets.example.com": {"bucket": "brand-assets"}, "*.docs.example.com": {"bucket": "docs", "prefix": "site/"}}
+	virtualHosts := map[string]VirtualHost{}
+	loadJSONEnv("VHOST_CONFIG", &virtualHosts)
+	config.VirtualHosts = make(map[string]VirtualHost, len(virtualHosts))
+	for host, vhost := range virtualHosts {
+		config.VirtualHosts[NormalizeHost(host)] = vhost
+	}
+
+	// Hotlink protection per bucket, "*" applies to every other bucket, e.g.
+	// {"images": {"allowed_domains": ["*.example.com"], "allow_empty": true, "action": "placeholder", "placeholder": "hotlink.png"}}
+	config.Hotlink = map[string]HotlinkPolicy{}
+	loadJSONEnv("HOTLINK_CONFIG", &config.Hotlink)
+
+	// Redirect and rewrite rules, matched in order against "/bucket/key", e.g.
+	// [{"match": "^/old-bucket/(.*)$", "target": "/new-bucket/$1", "status": 301},
+	//  {"match": "^/web/v1/(?P<file>.+)$", "target": "/web/releases/1.0/${file}"}]
+	// REWRITE_RULES_FILE is watched and reloaded, REWRITE_RULES is read once at startup
+	config.Rewrites = &RewriteRules{}
+	if rulesFile := os.Getenv("REWRITE_RULES_FILE"); rulesFile != "" {
+		watchFile("rewrite rules", rulesFile, reloadInterval("REWRITE_RULES_RELOAD_INTERVAL"), config.Rewrites.Load)
+	} else if rules := os.Getenv("REWRITE_RULES"); rules != "" {
+		if err := config.Rewrites.Load([]byte(rules)); err != nil {
+			log.Printf("Invalid REWRITE_RULES, ignoring: %v", err)
+		}
+	}
+
+	// Signed URLs: SIGNED_URL_KEYS holds "kid:secret" pairs (all of them verify, for rotation),
+	// SIGNED_URL_BUCKETS lists buckets that reject unsigned requests ("*" for all)
+	config.SignedURL.Keys = parseSigningKeys(os.Getenv("SIGNED_URL_KEYS"))
+	config.SignedURL.Buckets = splitList(os.Getenv("SIGNED_URL_BUCKETS"))
+	// Legacy access_key/secret_key query parameters are replaced by signed URLs, ALLOW_QUERY_CREDENTIALS=true
+	// re-enables them for clients that haven't migrated yet
+	config.SignedURL.AllowQueryCredentials = os.Getenv("ALLOW_QUERY_CREDENTIALS") == "true"
+	if config.SignedURL.AllowQueryCredentials {
+		log.Println("ALLOW_QUERY_CREDENTIALS is deprecated: MinIO credentials in query strings leak into logs and caches, use signed URLs instead")
+	}
+
+	// JWT authorization per bucket, e.g.
+	// {"*": {"mode": "public"}, "reports": {"mode": "authenticated"}, "tenants": {"mode": "claims", "claim": "cdn_access"}}
+	// Tokens come from "Authorization: Bearer" or the JWT_COOKIE_NAME cookie and are verified against
+	// JWT_KEY_FILE (JWKS or a raw HMAC secret), which is reloaded when it changes
+	config.Auth.Policies = map[string]AuthPolicy{}
+	loadJSONEnv("AUTH_POLICIES", &config.Auth.Policies)
+	config.Auth.Keys = &KeySet{}
+	if keyFile := os.Getenv("JWT_KEY_FILE"); keyFile != "" {
+		watchFile("JWT keys", keyFile, reloadInterval("JWT_KEY_RELOAD_INTERVAL"), config.Auth.Keys.Load)
+	}
+	config.Auth.CookieName = os.Getenv("JWT_COOKIE_NAME")
+	if config.Auth.CookieName == "" {
+		config.Auth.CookieName = "access_token"
+	}
+	config.Auth.Issuer = os.Getenv("JWT_ISSUER")
+	config.Auth.Audience = os.Getenv("JWT_AUDIENCE")
+
+	// Static-site mode: per-bucket settings from WEBSITE_CONFIG, e.g.
+	// {"my-spa": {"index_document": "index.html", "spa_fallback": "index.html", "error_404": "404.html"}}
+	// Buckets not listed may opt in with a marker object holding the same JSON (disabled when empty)
+	config.Website.Buckets = map[string]WebsiteConfig{}
+	loadJSONEnv("WEBSITE_CONFIG", &config.Website.Buckets)
+	config.Website.MarkerObject = os.Getenv("WEBSITE_MARKER_OBJECT")
+
+	// Listing API is opt-in per bucket ("*" enables every bucket)
+	config.Listing.Buckets = splitList(os.Getenv("LISTING_BUCKETS"))
+	config.Listing.MaxLimit, err = strconv.Atoi(os.Getenv("LISTING_MAX_LIMIT"))
+	if err != nil || config.Listing.MaxLimit <= 0 {
+		config.Listing.MaxLimit = 1000
+	}
+
+	// Object metadata forwarded as response headers; x-amz-meta-<name> picks one user field,
+	// x-amz-meta-* forwards all of them
+	config.Metadata.ExposedHeaders = splitList(os.Getenv("EXPOSED_METADATA_HEADERS"))
+	if len(config.Metadata.ExposedHeaders) == 0 {
+		config.Metadata.ExposedHeaders = []string{"Cache-Control", "Content-Language", "Content-Disposition", "Expires"}
+	}
 
 	// Grafana/OpenTelemetry
 	grafanaEndpoint := os.Getenv("GRAFANA_OTLP_ENDPOINT")
@@ -109,3 +323,40 @@ func LoadEnvConfig() *EnvConfig {
 
 	return &config
 }
+
+// ListingEnabled reports whether the listing API is enabled for bucket
+func (config *EnvConfig) ListingEnabled(bucket string) bool {
+	return containsOrWildcard(config.Listing.Buckets, bucket)
+}
+
+// loadJSONEnv decodes a JSON environment variable into dst, leaving dst untouched when unset or invalid
+func loadJSONEnv(name string, dst interface{}) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return
+	}
+	if err := json.Unmarshal([]byte(raw), dst); err != nil {
+		log.Printf("Invalid %s, ignoring: %v", name, err)
+	}
+}
+
+// splitList parses a comma separated environment value, dropping empty items
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsOrWildcard reports whether items contains value or the "*" wildcard
+func containsOrWildcard(items []string, value string) bool {
+	for _, item := range items {
+		if item == "*" || item == value {
+			return true
+		}
+	}
+	return false
+}
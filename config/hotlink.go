@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+)
+
+// Actions taken on a hotlinked request
+const (
+	HotlinkActionForbid      = "forbid"
+	HotlinkActionPlaceholder = "placeholder"
+	HotlinkActionRedirect    = "redirect"
+)
+
+// HotlinkPolicy restricts which sites may embed a bucket's objects
+type HotlinkPolicy struct {
+	// AllowedDomains are matched against the Referer (or Origin) host; "*.example.com" also
+	// matches example.com. The CDN's own host is always allowed.
+	AllowedDomains []string `json:"allowed_domains"`
+	// AllowEmpty lets requests without Referer and Origin through (direct visits, privacy settings)
+	AllowEmpty bool `json:"allow_empty"`
+	// Action is forbid (default 403), placeholder or redirect
+	Action string `json:"action"`
+	// Placeholder is the key served instead, from PlaceholderBucket or the requested bucket
+	Placeholder       string `json:"placeholder"`
+	PlaceholderBucket string `json:"placeholder_bucket"`
+	RedirectURL       string `json:"redirect_url"`
+}
+
+// HotlinkPolicy returns the hotlink policy of bucket, the "*" entry applies to buckets without their own.
+// Returns nil when hotlink protection is off.
+func (config *EnvConfig) HotlinkPolicy(bucket string) *HotlinkPolicy {
+	if policy, ok := config.Hotlink[bucket]; ok {
+		return &policy
+	}
+	if policy, ok := config.Hotlink["*"]; ok {
+		return &policy
+	}
+	return nil
+}
+
+// AllowsDomain reports whether host is one of the allowed domains
+func (policy *HotlinkPolicy) AllowsDomain(host string) bool {
+	host = NormalizeHost(host)
+	for _, domain := range policy.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if domain == host {
+			return true
+		}
+		if base, ok := strings.CutPrefix(domain, "*."); ok && (host == base || strings.HasSuffix(host, "."+base)) {
+			return true
+		}
+	}
+	return false
+}
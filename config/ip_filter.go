@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// IPRules are CIDR allow and deny lists. Deny wins; a non-empty allow list rejects every other address.
+type IPRules struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// IPFilterConfig holds the global lists and per-bucket lists, both are checked
+type IPFilterConfig struct {
+	IPRules
+	Buckets map[string]*IPRules `json:"buckets"`
+}
+
+// IPFilter holds the active lists, replaced atomically when the lists file is reloaded
+type IPFilter struct {
+	config atomic.Pointer[IPFilterConfig]
+}
+
+// Load parses and activates a JSON document such as
+// {"deny": ["203.0.113.0/24"], "buckets": {"internal": {"allow": ["10.0.0.0/8", "192.168.1.10"]}}}.
+// Invalid input leaves the current lists active.
+func (f *IPFilter) Load(data []byte) error {
+	var config IPFilterConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	if err := config.IPRules.compile(); err != nil {
+		return err
+	}
+	for bucket, rules := range config.Buckets {
+		if rules == nil {
+			continue
+		}
+		if err := rules.compile(); err != nil {
+			return fmt.Errorf("bucket %s: %w", bucket, err)
+		}
+	}
+
+	f.config.Store(&config)
+	return nil
+}
+
+// Allowed reports whether addr may access bucket
+func (f *IPFilter) Allowed(bucket string, addr netip.Addr) bool {
+	if f == nil {
+		return true
+	}
+	config := f.config.Load()
+	if config == nil {
+		return true
+	}
+
+	addr = addr.Unmap()
+	if !config.IPRules.allows(addr) {
+		return false
+	}
+	if rules := config.Buckets[bucket]; rules != nil && !rules.allows(addr) {
+		return false
+	}
+	return true
+}
+
+// Restricted reports whether bucket is limited to allow-listed addresses. Responses of such buckets
+// must not be stored by shared caches, which would serve them to any client.
+func (f *IPFilter) Restricted(bucket string) bool {
+	if f == nil {
+		return false
+	}
+	config := f.config.Load()
+	if config == nil {
+		return false
+	}
+
+	if len(config.IPRules.allow) > 0 {
+		return true
+	}
+	rules := config.Buckets[bucket]
+	return rules != nil && len(rules.allow) > 0
+}
+
+func (rules *IPRules) allows(addr netip.Addr) bool {
+	for _, prefix := range rules.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(rules.allow) == 0 {
+		return true
+	}
+	for _, prefix := range rules.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules *IPRules) compile() error {
+	var err error
+	if rules.allow, err = parsePrefixes(rules.Allow); err != nil {
+		return err
+	}
+	rules.deny, err = parsePrefixes(rules.Deny)
+	return err
+}
+
+// parsePrefixes parses CIDRs, plain addresses become single-address prefixes
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
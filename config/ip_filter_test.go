@@ -0,0 +1,193 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIPFilterAllowed(t *testing.T) {
+	var f IPFilter
+	err := f.Load([]byte(`{
+		"deny": ["203.0.113.0/24"],
+		"buckets": {
+			"internal": {"allow": ["10.0.0.0/8", "192.168.1.10"], "deny": ["10.9.0.0/16"]},
+			"v6": {"allow": ["2001:db8::/32"]},
+			"open": null
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		bucket string
+		addr   string
+		want   bool
+	}{
+		{"unlisted bucket", "media", "198.51.100.1", true},
+		{"global deny", "media", "203.0.113.7", false},
+		{"global deny wins over bucket allow", "internal", "203.0.113.7", false},
+		{"bucket allow CIDR", "internal", "10.1.2.3", true},
+		{"bucket allow single address", "internal", "192.168.1.10", true},
+		{"next to single address", "internal", "192.168.1.11", false},
+		{"outside bucket allow list", "internal", "198.51.100.1", false},
+		{"bucket deny wins over bucket allow", "internal", "10.9.1.1", false},
+		{"IPv4-mapped IPv6", "internal", "::ffff:10.1.2.3", true},
+		{"IPv6 allow", "v6", "2001:db8::1", true},
+		{"IPv6 outside allow", "v6", "2001:db9::1", false},
+		{"IPv4 against IPv6 allow", "v6", "10.1.2.3", false},
+		{"null bucket entry", "open", "198.51.100.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Allowed(tt.bucket, netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("Allowed(%q, %s) = %t, want %t", tt.bucket, tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilterGlobalAllow(t *testing.T) {
+	var f IPFilter
+	if err := f.Load([]byte(`{"allow": ["10.0.0.0/8"], "buckets": {"partners": {"allow": ["10.1.0.0/16"]}}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		bucket string
+		addr   string
+		want   bool
+	}{
+		{"media", "10.2.0.1", true},
+		{"media", "198.51.100.1", false},
+		// Both lists apply, the bucket list narrows the global one
+		{"partners", "10.1.0.1", true},
+		{"partners", "10.2.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := f.Allowed(tt.bucket, netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allowed(%q, %s) = %t, want %t", tt.bucket, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestIPFilterUnloaded(t *testing.T) {
+	var nilFilter *IPFilter
+	if !nilFilter.Allowed("media", netip.MustParseAddr("203.0.113.7")) || nilFilter.Restricted("media") {
+		t.Error("nil IPFilter must allow everything")
+	}
+
+	var empty IPFilter
+	if !empty.Allowed("media", netip.MustParseAddr("203.0.113.7")) || empty.Restricted("media") {
+		t.Error("unloaded IPFilter must allow everything")
+	}
+}
+
+func TestIPFilterRestricted(t *testing.T) {
+	tests := []struct {
+		name   string
+		lists  string
+		bucket string
+		want   bool
+	}{
+		{"no lists", `{}`, "media", false},
+		{"deny only", `{"deny": ["203.0.113.0/24"]}`, "media", false},
+		{"bucket allow list", `{"buckets": {"internal": {"allow": ["10.0.0.0/8"]}}}`, "internal", true},
+		{"other bucket's allow list", `{"buckets": {"internal": {"allow": ["10.0.0.0/8"]}}}`, "media", false},
+		{"bucket deny only", `{"buckets": {"media": {"deny": ["203.0.113.0/24"]}}}`, "media", false},
+		{"global allow list", `{"allow": ["10.0.0.0/8"]}`, "media", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f IPFilter
+			if err := f.Load([]byte(tt.lists)); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Restricted(tt.bucket); got != tt.want {
+				t.Errorf("Restricted(%q) = %t, want %t", tt.bucket, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilterLoadKeepsPreviousOnError(t *testing.T) {
+	var f IPFilter
+	if err := f.Load([]byte(`{"deny": ["203.0.113.0/24"]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		lists string
+	}{
+		{"invalid json", `{"deny": [`},
+		{"invalid global CIDR", `{"deny": ["203.0.113.0/33"]}`},
+		{"invalid address", `{"allow": ["not-an-ip"]}`},
+		{"invalid bucket entry", `{"buckets": {"internal": {"allow": ["10.0.0.0/8", "10.0.0"]}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := f.Load([]byte(tt.lists)); err == nil {
+				t.Fatal("Load succeeded, want error")
+			}
+			if f.Allowed("media", netip.MustParseAddr("203.0.113.7")) {
+				t.Error("previous deny list lost after a failed reload")
+			}
+		})
+	}
+}
+
+func TestIPFilterReloadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip-filter.json")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Mount updates may land within the same second, set distinct times explicitly
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocked := netip.MustParseAddr("203.0.113.7")
+	start := time.Now().Add(-time.Hour)
+
+	write(`{"deny": ["203.0.113.0/24"]}`, start)
+	var f IPFilter
+	watchFile("IP filter", path, 10*time.Millisecond, f.Load)
+	if f.Allowed("media", blocked) {
+		t.Fatal("initial file not loaded")
+	}
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for f.Allowed("media", blocked) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Allowed = %t after reload, want %t", !want, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	write(`{"deny": []}`, start.Add(time.Minute))
+	waitFor(true)
+
+	// A broken edit keeps the last good lists
+	write(`{"deny": [`, start.Add(2*time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if !f.Allowed("media", blocked) {
+		t.Error("invalid file replaced the active lists")
+	}
+
+	write(`{"deny": ["203.0.113.7"]}`, start.Add(3*time.Minute))
+	waitFor(false)
+}
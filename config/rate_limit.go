@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+// Keys rate limits are counted by
+const (
+	RateLimitKeyIP     = "ip"
+	RateLimitKeyToken  = "token"
+	RateLimitKeyBucket = "bucket"
+)
+
+// RateLimitRule limits requests and/or bytes per second for every client IP, token or bucket.
+// All matching rules apply.
+type RateLimitRule struct {
+	Name string `json:"name"`
+	// Key is ip (default), token (JWT subject or signed URL) or bucket
+	Key    string `json:"key"`
+	Bucket string `json:"bucket"`
+
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int64   `json:"burst"`
+	// Bytes are charged once the response is sent, an exhausted budget rejects the next request
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	BytesBurst     int64   `json:"bytes_burst"`
+}
+
+// Matches reports whether the rule applies to bucket
+func (rule *RateLimitRule) Matches(bucket string) bool {
+	return rule.Bucket == "" || rule.Bucket == "*" || rule.Bucket == bucket
+}
+
+// normalizeRateLimits fills in names, keys and default bursts of one second's worth
+func normalizeRateLimits(rules []RateLimitRule) []RateLimitRule {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i)
+		}
+		if rule.Key == "" {
+			rule.Key = RateLimitKeyIP
+		}
+		if rule.Burst <= 0 {
+			rule.Burst = int64(math.Max(1, math.Ceil(rule.RequestsPerSecond)))
+		}
+		if rule.BytesBurst <= 0 {
+			rule.BytesBurst = int64(math.Max(1, math.Ceil(rule.BytesPerSecond)))
+		}
+	}
+	return rules
+}
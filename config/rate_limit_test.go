@@ -0,0 +1,42 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeRateLimits(t *testing.T) {
+	got := normalizeRateLimits([]RateLimitRule{
+		{RequestsPerSecond: 2.5},
+		{Name: "downloads", Key: RateLimitKeyToken, Bucket: "media", BytesPerSecond: 1 << 20, Burst: 10, BytesBurst: 4 << 20},
+		{Name: "tiny", RequestsPerSecond: 0.1, BytesPerSecond: 0.5},
+	})
+
+	want := []RateLimitRule{
+		{Name: "rule-0", Key: RateLimitKeyIP, RequestsPerSecond: 2.5, Burst: 3, BytesBurst: 1},
+		{Name: "downloads", Key: RateLimitKeyToken, Bucket: "media", BytesPerSecond: 1 << 20, Burst: 10, BytesBurst: 4 << 20},
+		{Name: "tiny", Key: RateLimitKeyIP, RequestsPerSecond: 0.1, Burst: 1, BytesPerSecond: 0.5, BytesBurst: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeRateLimits =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRateLimitRuleMatches(t *testing.T) {
+	tests := []struct {
+		ruleBucket, bucket string
+		want               bool
+	}{
+		{"", "media", true},
+		{"*", "media", true},
+		{"media", "media", true},
+		{"media", "private", false},
+	}
+
+	for _, tt := range tests {
+		rule := RateLimitRule{Bucket: tt.ruleBucket}
+		if got := rule.Matches(tt.bucket); got != tt.want {
+			t.Errorf("rule bucket %q Matches(%q) = %t, want %t", tt.ruleBucket, tt.bucket, got, tt.want)
+		}
+	}
+}
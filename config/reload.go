@@ -0,0 +1,59 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultReloadInterval is how often reloadable config files are checked for changes
+const DefaultReloadInterval = 10 * time.Second
+
+// watchFile loads path once and then polls its modification time, calling load again whenever it changes.
+// Mounted ConfigMaps are updated in place by the kubelet, so edits apply without restarting the pod.
+// A file that fails to load keeps the previous settings active.
+func watchFile(name, path string, interval time.Duration, load func(data []byte) error) {
+	var lastMod time.Time
+
+	reload := func() {
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Printf("Failed to stat %s file %s: %v", name, path, err)
+			return
+		}
+		if info.ModTime().Equal(lastMod) {
+			return
+		}
+		lastMod = info.ModTime()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Failed to read %s file %s: %v", name, path, err)
+			return
+		}
+		if err := load(data); err != nil {
+			log.Printf("Invalid %s file %s, keeping previous settings: %v", name, path, err)
+			return
+		}
+		log.Printf("Loaded %s from %s", name, path)
+	}
+
+	reload()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			reload()
+		}
+	}()
+}
+
+// reloadInterval parses a reload interval in seconds, falling back to DefaultReloadInterval
+func reloadInterval(name string) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || seconds <= 0 {
+		return DefaultReloadInterval
+	}
+	return time.Duration(seconds) * time.Second
+}
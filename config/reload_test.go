@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchFileReloadsOnModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	start := time.Now().Add(-time.Hour)
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var loads []string
+	load := func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		loads = append(loads, string(data))
+		if string(data) == "invalid" {
+			return errors.New("invalid rules")
+		}
+		return nil
+	}
+	loaded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), loads...)
+	}
+	waitFor := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(loaded()) < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("loads = %q, want %d", loaded(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// A missing file is picked up once it appears
+	watchFile("rules", path, 10*time.Millisecond, load)
+	if got := loaded(); len(got) != 0 {
+		t.Fatalf("loads before the file exists = %q", got)
+	}
+
+	steps := []struct {
+		name    string
+		content string
+		modTime time.Time
+		want    []string
+	}{
+		{"file created", "v1", start, []string{"v1"}},
+		{"content changed, same modification time", "v2", start, []string{"v1"}},
+		{"modification time changed", "v3", start.Add(time.Minute), []string{"v1", "v3"}},
+		{"invalid file is read once", "invalid", start.Add(2 * time.Minute), []string{"v1", "v3", "invalid"}},
+		{"fixed file", "v4", start.Add(3 * time.Minute), []string{"v1", "v3", "invalid", "v4"}},
+		// Kubelet swaps the ConfigMap symlink, the new file may carry an older time
+		{"modification time moved back", "v5", start.Add(-time.Minute), []string{"v1", "v3", "invalid", "v4", "v5"}},
+	}
+
+	for _, s := range steps {
+		write(s.content, s.modTime)
+		waitFor(len(s.want))
+		// Give extra polls a chance to show up as unwanted reloads
+		time.Sleep(50 * time.Millisecond)
+
+		got := loaded()
+		if len(got) != len(s.want) {
+			t.Fatalf("%s: loads = %q, want %q", s.name, got, s.want)
+		}
+		for i := range got {
+			if got[i] != s.want[i] {
+				t.Fatalf("%s: loads = %q, want %q", s.name, got, s.want)
+			}
+		}
+	}
+}
+
+func TestReloadInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", DefaultReloadInterval},
+		{"30", 30 * time.Second},
+		{"0", DefaultReloadInterval},
+		{"-5", DefaultReloadInterval},
+		{"1m", DefaultReloadInterval},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("TEST_RELOAD_INTERVAL", tt.value)
+		if got := reloadInterval("TEST_RELOAD_INTERVAL"); got != tt.want {
+			t.Errorf("reloadInterval(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// RewriteRule redirects or internally rewrites requests whose "/bucket/key" path matches a regular expression
+type RewriteRule struct {
+	Name string `json:"name"`
+	// Match is a regular expression against the request path in "/bucket/key" form
+	Match string `json:"match"`
+	// Target may reference captures as $1 or ${name}. Rewrites need a "/bucket/key" target,
+	// redirects may also use an absolute URL. A query string in the target is kept.
+	Target string `json:"target"`
+	// Status is 301, 302, 307 or 308 for a redirect, 0 rewrites internally
+	Status int `json:"status"`
+	// PreserveQuery appends the original query string to the target, enabled by default
+	PreserveQuery *bool `json:"preserve_query"`
+
+	re *regexp.Regexp
+}
+
+// RewriteResult is the outcome of the first matching rule
+type RewriteResult struct {
+	RuleName string
+	// Status is the redirect status, 0 for an internal rewrite
+	Status int
+	// Location is the redirect target, Path and RawQuery the rewritten request
+	Location string
+	Path     string
+	RawQuery string
+}
+
+// IsRedirect reports whether the result is a redirect rather than an internal rewrite
+func (result *RewriteResult) IsRedirect() bool {
+	return result.Status != 0
+}
+
+// RewriteRules holds the active rules. They are replaced atomically, so a reload never
+// affects requests that are already being matched.
+type RewriteRules struct {
+	rules atomic.Pointer[[]RewriteRule]
+}
+
+// ParseRewriteRules decodes and compiles a JSON array of rules
+func ParseRewriteRules(data []byte) ([]RewriteRule, error) {
+	var rules []RewriteRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rules[i].Name, err)
+		}
+	}
+	return rules, nil
+}
+
+func (rule *RewriteRule) compile() error {
+	re, err := regexp.Compile(rule.Match)
+	if err != nil {
+		return err
+	}
+	rule.re = re
+
+	switch rule.Status {
+	case 0:
+		if !strings.HasPrefix(rule.Target, "/") {
+			return fmt.Errorf("rewrite target must be a /bucket/key path: %q", rule.Target)
+		}
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if rule.Target == "" {
+			return fmt.Errorf("redirect target is empty")
+		}
+	default:
+		return fmt.Errorf("unsupported status %d", rule.Status)
+	}
+	return nil
+}
+
+// Replace swaps in a new set of compiled rules
+func (r *RewriteRules) Replace(rules []RewriteRule) {
+	r.rules.Store(&rules)
+}
+
+// Load parses, compiles and activates a JSON array of rules. Invalid input leaves the current rules active.
+func (r *RewriteRules) Load(data []byte) error {
+	rules, err := ParseRewriteRules(data)
+	if err != nil {
+		return err
+	}
+	r.Replace(rules)
+	return nil
+}
+
+// Apply returns the result of the first rule matching requestPath, or nil when no rule matches
+func (r *RewriteRules) Apply(requestPath, rawQuery string) *RewriteResult {
+	if r == nil {
+		return nil
+	}
+	rules := r.rules.Load()
+	if rules == nil {
+		return nil
+	}
+
+	for i := range *rules {
+		rule := &(*rules)[i]
+		match := rule.re.FindStringSubmatchIndex(requestPath)
+		if match == nil {
+			continue
+		}
+
+		target := string(rule.re.ExpandString(nil, rule.Target, requestPath, match))
+		if rule.PreserveQuery == nil || *rule.PreserveQuery {
+			target = appendQuery(target, rawQuery)
+		}
+
+		result := &RewriteResult{RuleName: rule.Name, Status: rule.Status}
+		if result.RuleName == "" {
+			result.RuleName = fmt.Sprintf("rule-%d", i)
+		}
+		if result.IsRedirect() {
+			result.Location = target
+		} else {
+			result.Path, result.RawQuery, _ = strings.Cut(target, "?")
+		}
+		return result
+	}
+	return nil
+}
+
+// appendQuery adds rawQuery to target, after any query the target already carries
+func appendQuery(target, rawQuery string) string {
+	if rawQuery == "" {
+		return target
+	}
+	if strings.Contains(target, "?") {
+		return target + "&" + rawQuery
+	}
+	return target + "?" + rawQuery
+}
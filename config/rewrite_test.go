@@ -0,0 +1,156 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRewriteRulesApply(t *testing.T) {
+	rules, err := ParseRewriteRules([]byte(`[
+		{"name": "legacy", "match": "^/old/(.*)$", "target": "/media/$1", "status": 301},
+		{"name": "found", "match": "^/promo$", "target": "https://example.com/sale", "status": 302},
+		{"name": "temporary", "match": "^/tmp/(?P<file>[^/]+)$", "target": "/media/tmp/${file}", "status": 307},
+		{"name": "permanent", "match": "^/perm/(.*)$", "target": "/media/perm/$1?v=2", "status": 308},
+		{"name": "avatars", "match": "^/avatars/(?P<user>[^/]+)/(?P<size>\\d+)$", "target": "/users/${user}/avatar-${size}.png"},
+		{"name": "no-query", "match": "^/strip/(.*)$", "target": "/media/$1", "preserve_query": false},
+		{"name": "first", "match": "^/shared/(.*)$", "target": "/first/$1"},
+		{"name": "second", "match": "^/shared/(.*)$", "target": "/second/$1"},
+		{"match": "^/unnamed$", "target": "/media/unnamed"}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseRewriteRules: %v", err)
+	}
+
+	var r RewriteRules
+	r.Replace(rules)
+
+	tests := []struct {
+		name     string
+		path     string
+		rawQuery string
+		want     *RewriteResult
+	}{
+		{
+			name: "301 redirect with positional capture",
+			path: "/old/a/b.png",
+			want: &RewriteResult{RuleName: "legacy", Status: http.StatusMovedPermanently, Location: "/media/a/b.png"},
+		},
+		{
+			name:     "302 redirect to absolute URL keeps query",
+			path:     "/promo",
+			rawQuery: "utm=x",
+			want:     &RewriteResult{RuleName: "found", Status: http.StatusFound, Location: "https://example.com/sale?utm=x"},
+		},
+		{
+			name: "307 redirect with named capture",
+			path: "/tmp/report.pdf",
+			want: &RewriteResult{RuleName: "temporary", Status: http.StatusTemporaryRedirect, Location: "/media/tmp/report.pdf"},
+		},
+		{
+			name:     "308 redirect appends query after target query",
+			path:     "/perm/x.js",
+			rawQuery: "a=1",
+			want:     &RewriteResult{RuleName: "permanent", Status: http.StatusPermanentRedirect, Location: "/media/perm/x.js?v=2&a=1"},
+		},
+		{
+			name:     "internal rewrite with named captures",
+			path:     "/avatars/bao/64",
+			rawQuery: "t=1",
+			want:     &RewriteResult{RuleName: "avatars", Path: "/users/bao/avatar-64.png", RawQuery: "t=1"},
+		},
+		{
+			name:     "query dropped when preserve_query is false",
+			path:     "/strip/a.png",
+			rawQuery: "secret=1",
+			want:     &RewriteResult{RuleName: "no-query", Path: "/media/a.png"},
+		},
+		{
+			name: "first matching rule wins",
+			path: "/shared/a.png",
+			want: &RewriteResult{RuleName: "first", Path: "/first/a.png"},
+		},
+		{
+			name: "unnamed rule gets its index",
+			path: "/unnamed",
+			want: &RewriteResult{RuleName: "rule-8", Path: "/media/unnamed"},
+		},
+		{
+			name: "no match",
+			path: "/media/a.png",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Apply(tt.path, tt.rawQuery)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("Apply(%q) = %+v, want nil", tt.path, got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("Apply(%q) = nil, want %+v", tt.path, tt.want)
+			}
+			if *got != *tt.want {
+				t.Errorf("Apply(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+			if got.IsRedirect() != (tt.want.Status != 0) {
+				t.Errorf("IsRedirect() = %t", got.IsRedirect())
+			}
+		})
+	}
+}
+
+func TestParseRewriteRulesRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"invalid json", `[{"match": }]`},
+		{"invalid regex", `[{"match": "^/(unclosed$", "target": "/a/b"}]`},
+		{"unsupported status", `[{"match": "^/a$", "target": "/a/b", "status": 303}]`},
+		{"rewrite target without leading slash", `[{"match": "^/a$", "target": "a/b"}]`},
+		{"redirect without target", `[{"match": "^/a$", "status": 301}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRewriteRules([]byte(tt.json)); err == nil {
+				t.Errorf("ParseRewriteRules(%s) succeeded, want error", tt.json)
+			}
+		})
+	}
+}
+
+func TestRewriteRulesLoadKeepsPreviousOnError(t *testing.T) {
+	var r RewriteRules
+	if err := r.Load([]byte(`[{"name": "v1", "match": "^/a$", "target": "/media/a"}]`)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if err := r.Load([]byte(`[{"name": "v2", "match": "^/(a$", "target": "/media/b"}]`)); err == nil {
+		t.Fatal("Load accepted an invalid regex")
+	}
+	if err := r.Load([]byte(`not json`)); err == nil {
+		t.Fatal("Load accepted invalid JSON")
+	}
+
+	got := r.Apply("/a", "")
+	if got == nil || got.RuleName != "v1" || got.Path != "/media/a" {
+		t.Errorf("Apply after failed reload = %+v, want rule v1", got)
+	}
+}
+
+func TestRewriteRulesApplyWithoutRules(t *testing.T) {
+	var nilRules *RewriteRules
+	if got := nilRules.Apply("/a", ""); got != nil {
+		t.Errorf("nil RewriteRules Apply = %+v, want nil", got)
+	}
+
+	var empty RewriteRules
+	if got := empty.Apply("/a", ""); got != nil {
+		t.Errorf("unloaded RewriteRules Apply = %+v, want nil", got)
+	}
+}
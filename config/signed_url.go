@@ -0,0 +1,25 @@
+package config
+
+import (
+	"strings"
+)
+
+// parseSigningKeys parses "kid:secret" pairs separated by commas. Several keys can be active at once,
+// so a new key can be rolled out before the old one is retired.
+func parseSigningKeys(raw string) map[string]string {
+	keys := map[string]string{}
+	for _, item := range splitList(raw) {
+		kid, secret, ok := strings.Cut(item, ":")
+		kid, secret = strings.TrimSpace(kid), strings.TrimSpace(secret)
+		if !ok || kid == "" || secret == "" {
+			continue
+		}
+		keys[kid] = secret
+	}
+	return keys
+}
+
+// SignatureRequired reports whether bucket only serves signed URLs
+func (config *EnvConfig) SignatureRequired(bucket string) bool {
+	return containsOrWildcard(config.SignedURL.Buckets, bucket)
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSigningKeys(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{"2025:secret", map[string]string{"2025": "secret"}},
+		{" 2024 : old , 2025:new ", map[string]string{"2024": "old", "2025": "new"}},
+		{"2025:se:cret", map[string]string{"2025": "se:cret"}},
+		{"nokey,:nokid,empty:,2025:ok", map[string]string{"2025": "ok"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseSigningKeys(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSigningKeys(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"net"
+	"strings"
+)
+
+// VirtualHost maps a custom domain to a bucket, optionally scoped to a key prefix
+type VirtualHost struct {
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the request path, e.g. "public/" serves /logo.png from public/logo.png
+	Prefix string `json:"prefix"`
+}
+
+// VirtualHost returns the mapping for the request host, or nil when the host is not mapped.
+// Exact domains win over wildcard entries such as "*.example.com".
+func (config *EnvConfig) VirtualHost(host string) *VirtualHost {
+	if len(config.VirtualHosts) == 0 {
+		return nil
+	}
+
+	host = NormalizeHost(host)
+	if vhost, ok := config.VirtualHosts[host]; ok {
+		return &vhost
+	}
+
+	for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		if vhost, ok := config.VirtualHosts["*."+host]; ok {
+			return &vhost
+		}
+	}
+	return nil
+}
+
+// NormalizeHost lowercases a Host header value and strips the port and a trailing dot
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
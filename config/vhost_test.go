@@ -0,0 +1,61 @@
+package config
+
+import "testing"
+
+func TestVirtualHost(t *testing.T) {
+	config := &EnvConfig{VirtualHosts: map[string]VirtualHost{
+		"assets.example.com":    {Bucket: "brand-assets"},
+		"*.docs.example.com":    {Bucket: "docs", Prefix: "site/"},
+		"beta.docs.example.com": {Bucket: "docs-beta"},
+	}}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"assets.example.com", "brand-assets"},
+		{"ASSETS.Example.com", "brand-assets"},
+		{"assets.example.com:8443", "brand-assets"},
+		{"assets.example.com.", "brand-assets"},
+		{"v2.docs.example.com", "docs"},
+		{"a.b.docs.example.com", "docs"},
+		{"beta.docs.example.com", "docs-beta"},
+		// The wildcard only covers subdomains
+		{"docs.example.com", ""},
+		{"other.example.com", ""},
+		{"cdn.example.com", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got := ""
+		if vhost := config.VirtualHost(tt.host); vhost != nil {
+			got = vhost.Bucket
+		}
+		if got != tt.want {
+			t.Errorf("VirtualHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+
+	if vhost := (&EnvConfig{}).VirtualHost("assets.example.com"); vhost != nil {
+		t.Errorf("VirtualHost without VHOST_CONFIG = %+v, want nil", vhost)
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"Example.COM", "example.com"},
+		{"example.com:443", "example.com"},
+		{"example.com.", "example.com"},
+		{"[2001:db8::1]:8080", "2001:db8::1"},
+		{"10.0.0.1", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeHost(tt.host); got != tt.want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+// WebsiteConfig holds the static-site settings of a bucket
+type WebsiteConfig struct {
+	// IndexDocument is appended to directory-style paths (empty key or trailing slash)
+	IndexDocument string `json:"index_document"`
+	// SPAFallback is served with 200 for unknown paths so client-side routers can take over
+	SPAFallback string `json:"spa_fallback"`
+	// Error404 and Error403 are served with their status when the object is missing or denied
+	Error404 string `json:"error_404"`
+	Error403 string `json:"error_403"`
+}
+
+// IsEmpty reports whether no static-site feature is enabled
+func (w *WebsiteConfig) IsEmpty() bool {
+	return w == nil || (w.IndexDocument == "" && w.SPAFallback == "" && w.Error404 == "" && w.Error403 == "")
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// setValidatorHeaders sets ETag and Last-Modified headers so clients can revalidate later
+func setValidatorHeaders(c *gin.Context, objInfo *infra.ObjectInfo) {
+	if objInfo.ETag != "" {
+		c.Header("ETag", formatETag(objInfo.ETag))
+	}
+	if !objInfo.LastModified.IsZero() {
+		c.Header("Last-Modified", objInfo.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// checkNotModified answers with 304 Not Modified when the client copy is still fresh.
+// Returns true when the response has been written and the caller must stop.
+func (ctrl *Controller) checkNotModified(c *gin.Context, objInfo *infra.ObjectInfo) bool {
+	if !isNotModified(c.Request, objInfo) {
+		return false
+	}
+
+	setValidatorHeaders(c, objInfo)
+	ctrl.setCacheHeaders(c, objInfo, false)
+	if isCompressibleType(objInfo.ContentType) {
+		utils.AddVary(c, "Accept-Encoding")
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+// isNotModified evaluates If-None-Match and If-Modified-Since as described in RFC 9110 section 13.2.2
+func isNotModified(r *http.Request, objInfo *infra.ObjectInfo) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence, If-Modified-Since is ignored when it is present
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, objInfo.ETag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || objInfo.LastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates have second precision
+	return !objInfo.LastModified.Truncate(time.Second).After(since)
+}
+
+// etagListMatches reports whether a comma separated list of entity tags contains etag (weak comparison).
+// Tags of encoded variants match the original object they were derived from.
+func etagListMatches(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	target := opaqueETag(etag)
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if opaque := opaqueETag(candidate); opaque == target || stripVariantSuffix(opaque) == target {
+			return true
+		}
+	}
+	return false
+}
+
+// formatETag wraps a raw MinIO ETag in quotes as required by RFC 9110
+func formatETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// opaqueETag strips the weak prefix and quotes from an entity tag
+func opaqueETag(etag string) string {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strings.Trim(etag, `"`)
+}
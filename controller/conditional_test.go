@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+var testModified = time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC)
+
+func TestIfRangeMatches(t *testing.T) {
+	objInfo := &infra.ObjectInfo{ETag: "abc123", LastModified: testModified.Add(400 * time.Millisecond)}
+
+	tests := []struct {
+		name    string
+		ifRange string
+		want    bool
+	}{
+		{"absent", "", true},
+		{"matching strong etag", `"abc123"`, true},
+		{"different etag", `"def456"`, false},
+		{"weak etag never matches", `W/"abc123"`, false},
+		{"exact last-modified date", testModified.Format(http.TimeFormat), true},
+		{"older date", testModified.Add(-time.Second).Format(http.TimeFormat), false},
+		{"newer date", testModified.Add(time.Second).Format(http.TimeFormat), false},
+		{"invalid date", "yesterday", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			if got := ifRangeMatches(req, objInfo); got != tt.want {
+				t.Errorf("ifRangeMatches(If-Range: %s) = %t, want %t", tt.ifRange, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfRangeMatchesWithoutValidators(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/bucket/key", nil)
+	req.Header.Set("If-Range", `"abc123"`)
+	if ifRangeMatches(req, &infra.ObjectInfo{}) {
+		t.Error("etag If-Range matched an object without ETag")
+	}
+
+	req.Header.Set("If-Range", testModified.Format(http.TimeFormat))
+	if ifRangeMatches(req, &infra.ObjectInfo{}) {
+		t.Error("date If-Range matched an object without Last-Modified")
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	objInfo := &infra.ObjectInfo{ETag: "abc123", LastModified: testModified}
+
+	tests := []struct {
+		name   string
+		method string
+		header map[string]string
+		want   bool
+	}{
+		{"no conditions", http.MethodGet, nil, false},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": `"abc123"`}, true},
+		{"weak comparison", http.MethodGet, map[string]string{"If-None-Match": `W/"abc123"`}, true},
+		{"encoded variant etag", http.MethodGet, map[string]string{"If-None-Match": variantETag("abc123", EncodingBrotli)}, true},
+		{"etag in list", http.MethodGet, map[string]string{"If-None-Match": `"x", "abc123"`}, true},
+		{"wildcard", http.MethodGet, map[string]string{"If-None-Match": "*"}, true},
+		{"different etag", http.MethodGet, map[string]string{"If-None-Match": `"def456"`}, false},
+		{"etag wins over date", http.MethodGet, map[string]string{"If-None-Match": `"def456"`, "If-Modified-Since": testModified.Format(http.TimeFormat)}, false},
+		{"not modified since", http.MethodHead, map[string]string{"If-Modified-Since": testModified.Format(http.TimeFormat)}, true},
+		{"modified since", http.MethodGet, map[string]string{"If-Modified-Since": testModified.Add(-time.Second).Format(http.TimeFormat)}, false},
+		{"unsafe method", http.MethodPost, map[string]string{"If-None-Match": `"abc123"`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/bucket/key", nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			if got := isNotModified(req, objInfo); got != tt.want {
+				t.Errorf("isNotModified = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/repository"
+)
+
+// openDiskCopy returns the disk-cached copy of a large object when it matches the current version.
+// Entries are keyed like Redis entries so custom credentials and private scopes stay separate.
+func (ctrl *Controller) openDiskCopy(c *gin.Context, bucket, key string, objInfo *infra.ObjectInfo) (*os.File, bool) {
+	if objInfo.Size <= infra.SmallFileSizeLimit {
+		return nil, false
+	}
+	return ctrl.Repository.OpenDiskObject(scopedCacheKey(c, bucket, key), objInfo.ETag, objInfo.Size)
+}
+
+// createDiskCopy starts writing a large object to the disk cache while it is streamed, nil when it won't be cached
+func (ctrl *Controller) createDiskCopy(c *gin.Context, bucket, key string, objInfo *infra.ObjectInfo) *repository.DiskObjectWriter {
+	if objInfo.Size <= infra.SmallFileSizeLimit {
+		return nil
+	}
+	return ctrl.Repository.CreateDiskObject(scopedCacheKey(c, bucket, key), objInfo.ETag, objInfo.Size)
+}
+
+// commitDiskCopy publishes a disk copy once the stream is done, partial downloads are dropped
+func (ctrl *Controller) commitDiskCopy(ctx context.Context, diskCopy *repository.DiskObjectWriter, bucket, key string) {
+	err := diskCopy.Commit()
+	switch {
+	case err == nil:
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Cached large file on disk: bucket=%s, key=%s", bucket, key)
+	case errors.Is(err, repository.ErrDiskObjectIncomplete):
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Download incomplete, not cached on disk: bucket=%s, key=%s", bucket, key)
+	default:
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to cache large file on disk: bucket=%s, key=%s", bucket, key)
+	}
+}
+
+// teeReadCloser copies everything read from the object into its disk copy
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newTeeReadCloser(reader io.ReadCloser, diskCopy *repository.DiskObjectWriter) io.ReadCloser {
+	return teeReadCloser{Reader: io.TeeReader(reader, diskCopy), Closer: reader}
+}
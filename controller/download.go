@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+// filenameMetadataKeys are the user metadata fields (x-amz-meta-*) checked for a download filename
+var filenameMetadataKeys = []string{"Filename", "Original-Filename"}
+
+// setContentDisposition switches the response to download mode when ?download or ?filename= is present.
+// The header is computed per request and never stored in Redis, so inline and attachment responses of the
+// same object share the cached body without mixing up their headers.
+func setContentDisposition(c *gin.Context, key string, objInfo *infra.ObjectInfo) {
+	filename, requested := c.GetQuery("filename")
+	download, downloadRequested := c.GetQuery("download")
+	if !requested && (!downloadRequested || download == "0" || download == "false") {
+		return
+	}
+
+	filename = sanitizeFilename(filename)
+	if filename == "" {
+		filename = sanitizeFilename(metadataFilename(objInfo))
+	}
+	if filename == "" {
+		filename = sanitizeFilename(path.Base(key))
+	}
+
+	c.Header("Content-Disposition", formatContentDisposition("attachment", filename))
+}
+
+// metadataFilename returns the friendly filename stored in the object's user metadata, if any
+func metadataFilename(objInfo *infra.ObjectInfo) string {
+	for _, field := range filenameMetadataKeys {
+		if filename := objInfo.UserMetadata[field]; filename != "" {
+			return filename
+		}
+	}
+	return ""
+}
+
+// formatContentDisposition builds a Content-Disposition value with an ASCII filename fallback and the
+// UTF-8 filename* parameter (RFC 6266 section 4.3, RFC 5987)
+func formatContentDisposition(disposition, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+
+	fallback := asciiFilename(filename)
+	value := disposition + `; filename="` + fallback + `"`
+	if fallback != filename {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return value
+}
+
+// sanitizeFilename drops directory components and control characters so the name can't escape
+// the header or the user's download folder
+func sanitizeFilename(filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, filename)
+
+	if i := strings.LastIndexAny(filename, `/\`); i >= 0 {
+		filename = filename[i+1:]
+	}
+
+	filename = strings.TrimSpace(filename)
+	if filename == "." || filename == ".." {
+		return ""
+	}
+	return filename
+}
+
+// asciiFilename replaces characters that are not safe inside a quoted-string with an underscore
+func asciiFilename(filename string) string {
+	return strings.Map(func(r rune) rune {
+		if r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, filename)
+}
+
+// encodeRFC5987 percent-encodes everything outside the attr-char set of RFC 5987
+func encodeRFC5987(value string) string {
+	var b strings.Builder
+	for _, ch := range []byte(value) {
+		if isAttrChar(ch) {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+func isAttrChar(ch byte) bool {
+	switch {
+	case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", ch) >= 0
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"  spaced.txt  ", "spaced.txt"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\bao\file.txt`, "file.txt"},
+		{"dir/", ""},
+		{"..", ""},
+		{".", ""},
+		{"evil\r\nSet-Cookie: x=1.txt", "evilSet-Cookie: x=1.txt"},
+		{"tab\there\x7f.txt", "tabhere.txt"},
+		{"ảnh đẹp.jpg", "ảnh đẹp.jpg"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.filename); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestFormatContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"", "attachment"},
+		{"report.pdf", `attachment; filename="report.pdf"`},
+		{"ảnh.jpg", `attachment; filename="_nh.jpg"; filename*=UTF-8''%E1%BA%A3nh.jpg`},
+		{`say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{"100%.txt", `attachment; filename="100_.txt"; filename*=UTF-8''100%25.txt`},
+		{"a b.txt", `attachment; filename="a b.txt"`},
+	}
+
+	for _, tt := range tests {
+		if got := formatContentDisposition("attachment", tt.filename); got != tt.want {
+			t.Errorf("formatContentDisposition(%q) = %s, want %s", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeRFC5987(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain-name_1.txt", "plain-name_1.txt"},
+		{"a b", "a%20b"},
+		{"€", "%E2%82%AC"},
+		{"a;b,c'd", "a%3Bb%2Cc%27d"},
+		{"!#$&+^`|~", "!#$&+^`|~"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeRFC5987(tt.value); got != tt.want {
+			t.Errorf("encodeRFC5987(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSetContentDisposition(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withMetadata := &infra.ObjectInfo{UserMetadata: map[string]string{"Original-Filename": "Invoice May.pdf"}}
+
+	tests := []struct {
+		name    string
+		query   string
+		objInfo *infra.ObjectInfo
+		want    string
+	}{
+		{"inline by default", "", &infra.ObjectInfo{}, ""},
+		{"download disabled", "?download=0", &infra.ObjectInfo{}, ""},
+		{"download uses the key name", "?download", &infra.ObjectInfo{}, `attachment; filename="a1b2.pdf"`},
+		{"download prefers metadata filename", "?download=1", withMetadata, `attachment; filename="Invoice May.pdf"`},
+		{"filename overrides metadata", "?filename=custom.pdf", withMetadata, `attachment; filename="custom.pdf"`},
+		{"unsafe filename falls back", "?filename=..", withMetadata, `attachment; filename="Invoice May.pdf"`},
+		{"path in filename stripped", "?filename=../x.pdf", &infra.ObjectInfo{}, `attachment; filename="x.pdf"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/docs/2024/a1b2.pdf"+tt.query, nil)
+
+			setContentDisposition(c, "2024/a1b2.pdf", tt.objInfo)
+			if got := w.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
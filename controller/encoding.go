@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/repository"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+const (
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+	EncodingGzip   = "gzip"
+
+	// MinCompressSize skips compression for bodies too small to benefit from it
+	MinCompressSize = 1024
+)
+
+// supportedEncodings lists the encodings we can produce, in server preference order
+var supportedEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+// selectEncoding picks the content encoding for a full (non-range) response and sets Vary when the
+// representation depends on Accept-Encoding. Returns "" when the body must be sent as is.
+func selectEncoding(c *gin.Context, objInfo *infra.ObjectInfo) string {
+	if !isCompressibleType(objInfo.ContentType) {
+		return ""
+	}
+
+	utils.AddVary(c, "Accept-Encoding")
+	if objInfo.Size < MinCompressSize || objInfo.ContentEncoding != "" {
+		return ""
+	}
+	return negotiateEncoding(c.GetHeader("Accept-Encoding"), supportedEncodings)
+}
+
+// negotiateEncoding returns the best encoding from available accepted by the Accept-Encoding header.
+// Ties on q-value are broken by the order of available.
+func negotiateEncoding(acceptEncoding string, available []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	accepted := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range available {
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// isCompressibleType reports whether a content type is text-like and worth compressing.
+// Images, video, archives and other already compressed media are left alone.
+func isCompressibleType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	compressibleTypes := []string{
+		"application/javascript",
+		"application/x-javascript",
+		"application/json",
+		"application/ld+json",
+		"application/manifest+json",
+		"application/xml",
+		"application/xhtml+xml",
+		"application/rss+xml",
+		"application/atom+xml",
+		"application/wasm",
+		"application/vnd.ms-fontobject",
+		"application/x-font-ttf",
+		"font/ttf",
+		"font/otf",
+		"image/svg+xml",
+		"image/x-icon",
+		"image/vnd.microsoft.icon",
+		"image/bmp",
+	}
+	for _, compressType := range compressibleTypes {
+		if mediaType == compressType {
+			return true
+		}
+	}
+
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// newEncoder wraps w with a compressor for the given encoding
+func newEncoder(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case EncodingBrotli:
+		return brotli.NewWriterLevel(w, 5), nil
+	case EncodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	case EncodingGzip:
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// compressBytes encodes data in memory, used for small files that are cached per encoding
+func compressBytes(data []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder, err := newEncoder(&buf, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := encoder.Write(data); err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("%s encode failed: %w", encoding, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("%s encode failed: %w", encoding, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// variantETag derives the ETag of an encoded variant from the original object's ETag. It is weak because the
+// variant is not byte-identical to the stored object, and suffixed so each encoding has its own tag.
+func variantETag(etag, encoding string) string {
+	if etag == "" {
+		return ""
+	}
+	return `W/"` + opaqueETag(etag) + "-" + encoding + `"`
+}
+
+// stripVariantSuffix returns the original ETag of a tag built by variantETag
+func stripVariantSuffix(etag string) string {
+	for _, encoding := range []string{EncodingBrotli, EncodingZstd, EncodingGzip} {
+		if trimmed, ok := strings.CutSuffix(etag, "-"+encoding); ok {
+			return trimmed
+		}
+	}
+	return etag
+}
+
+// encodeVariant compresses an identity body and caches the result under its variant key.
+// Falls back to the identity body when compression fails or does not make it smaller.
+func (ctrl *Controller) encodeVariant(ctx context.Context, cacheKey string, data []byte, objInfo *infra.ObjectInfo, encoding string) ([]byte, string) {
+	if encoding == "" {
+		return data, ""
+	}
+
+	encoded, err := compressBytes(data, encoding)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to encode %s as %s", cacheKey, encoding)
+		return data, ""
+	}
+	if len(encoded) >= len(data) {
+		return data, ""
+	}
+
+	// Cache encoded variant for future requests (async, don't block response)
+	ctrl.storeInCache(repository.VariantKey(cacheKey, encoding), encoded, objInfo)
+
+	return encoded, encoding
+}
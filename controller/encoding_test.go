@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{"absent", "", ""},
+		{"single", "gzip", EncodingGzip},
+		{"server preference on equal q", "gzip, zstd, br", EncodingBrotli},
+		{"case insensitive", "GZIP", EncodingGzip},
+		{"higher q wins", "br;q=0.5, gzip;q=0.9", EncodingGzip},
+		{"spaces around params", "br ; q=0.2 , zstd ; q=0.8", EncodingZstd},
+		{"refused with q=0", "br;q=0, gzip", EncodingGzip},
+		{"wildcard", "*", EncodingBrotli},
+		{"wildcard with explicit refusal", "br;q=0, *;q=0.5", EncodingZstd},
+		{"explicit entry beats wildcard", "*;q=0.1, gzip;q=0.5", EncodingGzip},
+		{"identity only", "identity", ""},
+		{"everything refused", "*;q=0", ""},
+		{"unsupported only", "deflate, compress", ""},
+		{"invalid q keeps default", "gzip;q=abc", EncodingGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding, supportedEncodings); got != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCompressibleType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/html; charset=utf-8", true},
+		{"TEXT/CSS", true},
+		{"application/javascript", true},
+		{"application/json", true},
+		{"application/vnd.api+json", true},
+		{"image/svg+xml", true},
+		{"image/png", false},
+		{"video/mp4", false},
+		{"application/zip", false},
+		{"application/octet-stream", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isCompressibleType(tt.contentType); got != tt.want {
+			t.Errorf("isCompressibleType(%q) = %t, want %t", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestCompressBytesRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("body { margin: 0; padding: 0; }\n", 200))
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		EncodingBrotli: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		EncodingZstd:   func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+		EncodingGzip:   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	}
+
+	for _, encoding := range supportedEncodings {
+		t.Run(encoding, func(t *testing.T) {
+			encoded, err := compressBytes(data, encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(encoded) >= len(data) {
+				t.Errorf("encoded %d bytes into %d", len(data), len(encoded))
+			}
+
+			decoder, err := decoders[encoding](bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := io.ReadAll(decoder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Error("decoded body differs from the original")
+			}
+		})
+	}
+
+	if _, err := compressBytes(data, "deflate"); err == nil {
+		t.Error("compressBytes accepted an unsupported encoding")
+	}
+}
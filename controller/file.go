@@ -2,7 +2,6 @@ package controller
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
 	"strconv"
@@ -11,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/minio/minio-go/v7"
 	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/repository"
 	"github.com/tnqbao/gau-cdn-service/utils"
 )
 
@@ -32,49 +32,36 @@ func (ctrl *Controller) GetFile(c *gin.Context) {
 		return
 	}
 
-	// Clean path - remove leading slash
+	// ?list turns the request into a JSON listing of the bucket
+	if _, ok := c.GetQuery("list"); ok {
+		ctrl.ListFiles(c)
+		return
+	}
+
+	// Clean path - remove leading slash, directory-style paths map to the index document in static-site mode
 	key := strings.TrimPrefix(path, "/")
+	website := ctrl.websiteConfig(ctx, bucket)
+	key = resolveIndexDocument(key, website)
 	if key == "" {
 		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Invalid file path")
 		utils.JSON400(c, "invalid file path")
 		return
 	}
 
-	// Get optional access_key and secret_key from query params
-	accessKey := c.Query("access_key")
-	secretKey := c.Query("secret_key")
-
-	// Determine which MinIO client to use
-	var minioClient *infra.MinioClient
-	var err error
-
-	if accessKey != "" && secretKey != "" {
-		// Create temporary client with custom credentials
-		minioClient, err = infra.NewMinioClientWithCredentials(ctrl.Config.EnvConfig, accessKey, secretKey)
-		if err != nil {
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to create MinIO client with custom credentials")
-			utils.JSON500(c, "failed to initialize storage client")
-			return
-		}
-		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Using custom credentials for bucket=%s, key=%s", bucket, key)
-	} else {
-		// Use default client from controller
-		minioClient = ctrl.Infra.MinioClient
-		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Using default credentials for bucket=%s, key=%s", bucket, key)
+	minioClient, ok := ctrl.resolveMinioClient(c, ctx, bucket, key)
+	if !ok {
+		return
 	}
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Request: bucket=%s, key=%s", bucket, key)
 
-	// Check for Range header (video streaming, resume download)
-	rangeHeader := c.GetHeader("Range")
-	if rangeHeader != "" {
-		ctrl.handleRangeRequest(c, ctx, minioClient, bucket, key, rangeHeader)
-		return
-	}
-
 	// Get file metadata to determine size
 	objInfo, err := minioClient.HeadObject(ctx, bucket, key)
 	if err != nil {
+		// Static-site buckets answer with their SPA fallback or custom error pages
+		if website != nil && ctrl.serveWebsiteFallback(c, ctx, minioClient, bucket, key, website, err) {
+			return
+		}
 		// Check if it's an Access Denied error
 		if infra.IsAccessDeniedError(err) {
 			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Access denied for bucket=%s, key=%s", bucket, key)
@@ -87,16 +74,71 @@ func (ctrl *Controller) GetFile(c *gin.Context) {
 	}
 
 	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] File info: size=%d, type=%s", objInfo.Size, objInfo.ContentType)
+	ctrl.refreshObjectMeta(c, minioClient, bucket, key, objInfo)
+
+	// Client already holds the current version, skip the body entirely
+	if ctrl.checkNotModified(c, objInfo) {
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Not modified: bucket=%s, key=%s", bucket, key)
+		return
+	}
+
+	// ?download / ?filename= turn the response into an attachment
+	setContentDisposition(c, key, objInfo)
 
+	// Check for Range header (video streaming, resume download)
+	rangeHeader := c.GetHeader("Range")
+	signed := utils.SignedURLFromContext(c)
+	if signed != nil && signed.ByteRange != "" && rangeHeader == "" {
+		// A range-restricted signed URL serves its signed range when the client asks for the whole object
+		rangeHeader = "bytes=" + signed.ByteRange
+	}
+	if rangeHeader != "" {
+		// If-Range mismatch means the object changed since the client's partial copy, send it whole.
+		// Range-restricted signed URLs never fall back to the whole object.
+		if ifRangeMatches(c.Request, objInfo) || (signed != nil && signed.ByteRange != "") {
+			ctrl.handleRangeRequest(c, ctx, minioClient, bucket, key, objInfo, rangeHeader)
+			return
+		}
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] If-Range mismatch, serving full object: bucket=%s, key=%s", bucket, key)
+	}
+
+	// Compressible text assets are encoded on the fly when the client accepts it
+	encoding := selectEncoding(c, objInfo)
+	if encoding != "" {
+		// Prefer precompressed siblings (app.js.br, app.js.gz) uploaded by the build pipeline
+		if siblingKey, siblingInfo := ctrl.findPrecompressedSibling(c, ctx, minioClient, bucket, key, objInfo); siblingInfo != nil {
+			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Serving precompressed sibling: bucket=%s, key=%s, encoding=%s", bucket, siblingKey, siblingInfo.ContentEncoding)
+			ctrl.serveObject(c, ctx, minioClient, bucket, siblingKey, siblingInfo, "")
+			return
+		}
+	}
+
+	ctrl.serveObject(c, ctx, minioClient, bucket, key, objInfo, encoding)
+}
+
+// serveObject sends the full object, from the Redis cache for small files or streamed from MinIO for large ones
+func (ctrl *Controller) serveObject(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key string, objInfo *infra.ObjectInfo, encoding string) {
 	// For small files < 50MB, try cache first
 	if objInfo.Size <= infra.SmallFileSizeLimit {
-		cacheKey := fmt.Sprintf("cdn:%s:%s", bucket, key)
-		if data, contentType, err := ctrl.Repository.GetImage(ctx, cacheKey); err == nil && len(data) > 0 {
+		cacheKey := scopedCacheKey(c, bucket, key)
+
+		// Each encoded variant is cached under its own key so it is compressed only once
+		if encoding != "" {
+			variantKey := repository.VariantKey(cacheKey, encoding)
+			if data, _, err := ctrl.Repository.GetImage(ctx, variantKey, objInfo.ETag); err == nil && len(data) > 0 {
+				ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Cache hit for key: %s", variantKey)
+				ctrl.writeBody(c, objInfo, data, encoding, true)
+				return
+			}
+		}
+
+		// Content-Type comes from the HEAD response, the same cached body may be served under another type
+		// when it is a precompressed sibling. Bodies cached for an older ETag are misses, so the validators
+		// sent below always describe the bytes that go with them.
+		if data, _, err := ctrl.Repository.GetImage(ctx, cacheKey, objInfo.ETag); err == nil && len(data) > 0 {
 			ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Cache hit for key: %s", cacheKey)
-			ctrl.setCacheHeaders(c, true)
-			c.Header("Content-Length", strconv.FormatInt(int64(len(data)), 10))
-			c.Header("ETag", objInfo.ETag)
-			c.Data(http.StatusOK, contentType, data)
+			data, encoding = ctrl.encodeVariant(ctx, cacheKey, data, objInfo, encoding)
+			ctrl.writeBody(c, objInfo, data, encoding, true)
 			return
 		}
 
@@ -108,15 +150,55 @@ func (ctrl *Controller) GetFile(c *gin.Context) {
 		}
 
 		// Cache miss, fetch and cache small file
-		ctrl.handleSmallFileWithCache(c, ctx, minioClient, bucket, key, cacheKey, objInfo)
+		ctrl.handleSmallFileWithCache(c, ctx, minioClient, bucket, key, cacheKey, objInfo, encoding)
 	} else {
-		// Large file: stream directly without caching
-		ctrl.handleLargeFileStream(c, ctx, minioClient, bucket, key, objInfo)
+		// Large file: streamed, from the disk cache when enabled
+		ctrl.handleLargeFileStream(c, ctx, minioClient, bucket, key, objInfo, encoding)
 	}
 }
 
+// resolveMinioClient picks the MinIO client for the request: a temporary client when access_key and
+// secret_key are supplied in the query string, the default client otherwise.
+// Returns false when an error response has already been written.
+func (ctrl *Controller) resolveMinioClient(c *gin.Context, ctx context.Context, bucket, key string) (*infra.MinioClient, bool) {
+	// Verified signed URLs are served with the server's credentials, private buckets included
+	if signed := utils.SignedURLFromContext(c); signed != nil {
+		ctrl.setAccessScope(c, bucket, "", "")
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Using signed URL: kid=%s, bucket=%s, key=%s", signed.KeyID, bucket, key)
+		return ctrl.Infra.MinioClient, true
+	}
+
+	// Get optional access_key and secret_key from query params
+	accessKey := c.Query("access_key")
+	secretKey := c.Query("secret_key")
+
+	if accessKey != "" && secretKey != "" {
+		if !ctrl.Config.EnvConfig.SignedURL.AllowQueryCredentials {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Rejected query credentials for bucket=%s, key=%s", bucket, key)
+			utils.JSON401(c, "query credentials are disabled, use a signed URL")
+			return nil, false
+		}
+
+		// Clients for custom credentials are pooled so their connections are reused
+		minioClient, err := ctrl.Infra.MinioClientPool.Get(accessKey, secretKey)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to create MinIO client with custom credentials")
+			utils.JSON500(c, "failed to initialize storage client")
+			return nil, false
+		}
+		ctrl.setAccessScope(c, bucket, accessKey, secretKey)
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Using custom credentials for bucket=%s, key=%s", bucket, key)
+		return minioClient, true
+	}
+
+	// Use default client from controller
+	ctrl.setAccessScope(c, bucket, "", "")
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Using default credentials for bucket=%s, key=%s", bucket, key)
+	return ctrl.Infra.MinioClient, true
+}
+
 // handleSmallFileWithCache streams small files and caches them in Redis
-func (ctrl *Controller) handleSmallFileWithCache(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key, cacheKey string, objInfo *infra.ObjectInfo) {
+func (ctrl *Controller) handleSmallFileWithCache(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key, cacheKey string, objInfo *infra.ObjectInfo, encoding string) {
 	// Validate size before allocating buffer
 	if objInfo.Size <= 0 {
 		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, nil, "[GetFile] Invalid file size: bucket=%s, key=%s, size=%d", bucket, key, objInfo.Size)
@@ -156,23 +238,39 @@ func (ctrl *Controller) handleSmallFileWithCache(c *gin.Context, ctx context.Con
 	data = data[:n]
 
 	// Cache in Redis for future requests (async, don't block response)
-	go func() {
-		if err := ctrl.Repository.SetImage(context.Background(), cacheKey, data, objInfo.ContentType); err != nil {
-			ctrl.Provider.LoggerProvider.ErrorWithContextf(context.Background(), err, "[GetFile] Failed to cache file: %s", cacheKey)
-		}
-	}()
+	ctrl.storeInCache(cacheKey, data, objInfo)
 
 	// Set response headers and send data
-	ctrl.setCacheHeaders(c, false)
+	body, encoding := ctrl.encodeVariant(ctx, cacheKey, data, objInfo, encoding)
+	ctrl.writeBody(c, objInfo, body, encoding, false)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Served small file: bucket=%s, key=%s, size=%d, encoding=%s", bucket, key, len(body), encoding)
+}
+
+// writeBody sends an in-memory body with validator and cache headers
+func (ctrl *Controller) writeBody(c *gin.Context, objInfo *infra.ObjectInfo, data []byte, encoding string, fromCache bool) {
+	ctrl.setCacheHeaders(c, objInfo, fromCache)
 	c.Header("Content-Length", strconv.FormatInt(int64(len(data)), 10))
-	c.Header("ETag", objInfo.ETag)
+	setValidatorHeaders(c, objInfo)
+	if encoding != "" {
+		c.Header("Content-Encoding", encoding)
+		c.Header("ETag", variantETag(objInfo.ETag, encoding))
+	} else if objInfo.ContentEncoding != "" {
+		c.Header("Content-Encoding", objInfo.ContentEncoding)
+	}
 	c.Data(http.StatusOK, objInfo.ContentType, data)
-
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Served small file: bucket=%s, key=%s, size=%d", bucket, key, len(data))
 }
 
-// handleLargeFileStream streams large files directly from MinIO to client without loading into memory
-func (ctrl *Controller) handleLargeFileStream(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key string, objInfo *infra.ObjectInfo) {
+// handleLargeFileStream streams large files to the client without loading them into memory. The body comes
+// from the disk cache when it holds the current version, otherwise from MinIO while a disk copy is written.
+func (ctrl *Controller) handleLargeFileStream(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key string, objInfo *infra.ObjectInfo, encoding string) {
+	if file, ok := ctrl.openDiskCopy(c, bucket, key, objInfo); ok {
+		defer file.Close()
+		ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Disk cache hit: bucket=%s, key=%s", bucket, key)
+		ctrl.streamLargeFile(c, ctx, bucket, key, objInfo, encoding, file, true)
+		return
+	}
+
 	// Get object stream from MinIO
 	reader, _, err := minioClient.GetObjectStream(ctx, bucket, key, minio.GetObjectOptions{})
 	if err != nil {
@@ -188,181 +286,104 @@ func (ctrl *Controller) handleLargeFileStream(c *gin.Context, ctx context.Contex
 	}
 	defer reader.Close()
 
-	// Set headers before streaming
-	c.Header("Content-Type", objInfo.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(objInfo.Size, 10))
-	c.Header("ETag", objInfo.ETag)
-	c.Header("Accept-Ranges", "bytes")
-	ctrl.setCacheHeaders(c, false)
-	c.Status(http.StatusOK)
-
-	// Stream directly to response writer with buffer
-	buf := make([]byte, infra.StreamBufferSize)
-	written, err := io.CopyBuffer(c.Writer, reader, buf)
-	if err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Stream failed: bucket=%s, key=%s, written=%d", bucket, key, written)
-		// Can't send error response as headers already sent
-		return
+	// The first client's download fills the disk cache, the raw object is stored before encoding
+	if diskCopy := ctrl.createDiskCopy(c, bucket, key, objInfo); diskCopy != nil {
+		defer ctrl.commitDiskCopy(ctx, diskCopy, bucket, key)
+		reader = newTeeReadCloser(reader, diskCopy)
 	}
 
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Streamed large file: bucket=%s, key=%s, size=%d", bucket, key, written)
+	ctrl.streamLargeFile(c, ctx, bucket, key, objInfo, encoding, reader, false)
 }
 
-// handleRangeRequest handles HTTP Range requests for video streaming and resume download
-func (ctrl *Controller) handleRangeRequest(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key, rangeHeader string) {
-	// Get file metadata first
-	objInfo, err := minioClient.HeadObject(ctx, bucket, key)
-	if err != nil {
-		// Check if it's an Access Denied error
-		if infra.IsAccessDeniedError(err) {
-			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Access denied for bucket=%s, key=%s", bucket, key)
-			utils.JSON403(c, "Access Denied")
-			return
-		}
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] HEAD request failed for range request: bucket=%s, key=%s", bucket, key)
-		utils.JSON404(c, "file not found")
-		return
-	}
-
-	// Parse Range header: "bytes=start-end"
-	start, end, err := parseRangeHeader(rangeHeader, objInfo.Size)
-	if err != nil {
-		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Invalid range header: %s, error: %v", rangeHeader, err)
-		c.Header("Content-Range", fmt.Sprintf("bytes */%d", objInfo.Size))
-		c.Status(http.StatusRequestedRangeNotSatisfiable)
-		return
-	}
-
-	contentLength := end - start + 1
-
-	// Set response headers for partial content
+// streamLargeFile sends the full body of a large object read from src
+func (ctrl *Controller) streamLargeFile(c *gin.Context, ctx context.Context, bucket, key string, objInfo *infra.ObjectInfo, encoding string, src io.Reader, fromCache bool) {
+	// Set headers before streaming
 	c.Header("Content-Type", objInfo.ContentType)
-	c.Header("Content-Length", strconv.FormatInt(contentLength, 10))
-	c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, objInfo.Size))
+	setValidatorHeaders(c, objInfo)
 	c.Header("Accept-Ranges", "bytes")
-	c.Header("ETag", objInfo.ETag)
-	ctrl.setCacheHeaders(c, false)
-	c.Status(http.StatusPartialContent)
-
-	// Stream range to client
-	reader, _, err := ctrl.Infra.MinioClient.GetObjectWithRange(ctx, bucket, key, start, end)
-	if err != nil {
-		// Check if it's an Access Denied error
-		if infra.IsAccessDeniedError(err) {
-			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Access denied for bucket=%s, key=%s", bucket, key)
-			utils.JSON403(c, "Access Denied")
+	ctrl.setCacheHeaders(c, objInfo, fromCache)
+
+	// Bandwidth shaping applies to the bytes on the wire, after encoding
+	out, done := ctrl.throttle(c, bucket, c.Writer)
+	defer done()
+	dst := out
+	if encoding != "" {
+		// Encoded length is unknown upfront, the body goes out chunked
+		encoder, err := newEncoder(out, encoding)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to create encoder: bucket=%s, key=%s", bucket, key)
+			utils.JSON500(c, "failed to encode file")
 			return
 		}
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Range request failed: bucket=%s, key=%s, range=%d-%d", bucket, key, start, end)
-		return
+		defer encoder.Close()
+		dst = encoder
+		c.Header("Content-Encoding", encoding)
+		c.Header("ETag", variantETag(objInfo.ETag, encoding))
+	} else {
+		c.Header("Content-Length", strconv.FormatInt(objInfo.Size, 10))
+		if objInfo.ContentEncoding != "" {
+			c.Header("Content-Encoding", objInfo.ContentEncoding)
+		}
 	}
-	defer reader.Close()
+	c.Status(http.StatusOK)
 
+	// Stream directly to response writer with buffer
 	buf := make([]byte, infra.StreamBufferSize)
-	written, err := copyBufferWithLimit(c.Writer, reader, buf, contentLength)
+	written, err := io.CopyBuffer(dst, src, buf)
 	if err != nil {
-		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Range stream failed: bucket=%s, key=%s, written=%d", bucket, key, written)
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Stream failed: bucket=%s, key=%s, written=%d", bucket, key, written)
+		// Can't send error response as headers already sent
 		return
 	}
 
-	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Served range request: bucket=%s, key=%s, range=%d-%d, written=%d", bucket, key, start, end, written)
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Streamed large file: bucket=%s, key=%s, size=%d, from_cache=%t", bucket, key, written, fromCache)
 }
 
-// setCacheHeaders sets appropriate cache control headers
-func (ctrl *Controller) setCacheHeaders(c *gin.Context, fromCache bool) {
+// setCacheHeaders sets cache control headers from the object's cache policy and the allowlisted object metadata.
+// Cache-Control set by a matching CACHE_RULES entry wins over the object's metadata, which wins over CACHE_TIME.
+func (ctrl *Controller) setCacheHeaders(c *gin.Context, objInfo *infra.ObjectInfo, fromCache bool) {
 	if fromCache {
 		c.Header("X-From-Cache", "true")
 	} else {
 		c.Header("X-From-Cache", "false")
 	}
 
-	cacheTime := ctrl.Config.EnvConfig.Limit.CacheTime
-	if cacheTime > 0 {
-		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", cacheTime))
+	policy := ctrl.Config.EnvConfig.CachePolicy(objInfo.Bucket, objInfo.Key, objInfo.ContentType)
+	if policy.RuleName != "" {
+		c.Header("X-Cache-Rule", policy.RuleName)
+	}
+
+	if policy.CacheControl != "" {
+		c.Header("Cache-Control", policy.CacheControl)
 	} else {
 		c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
 		c.Header("Pragma", "no-cache")
 		c.Header("Expires", "0")
 	}
-}
 
-// parseRangeHeader parses HTTP Range header and returns start, end positions
-func parseRangeHeader(rangeHeader string, fileSize int64) (int64, int64, error) {
-	// Format: bytes=start-end or bytes=start- or bytes=-suffix
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		return 0, 0, fmt.Errorf("invalid range format")
+	ctrl.setMetadataHeaders(c, objInfo)
+	if policy.RuleCacheControl {
+		c.Header("Cache-Control", policy.CacheControl)
 	}
 
-	rangeSpec := strings.TrimPrefix(rangeHeader, "bytes=")
-	parts := strings.Split(rangeSpec, "-")
-	if len(parts) != 2 {
-		return 0, 0, fmt.Errorf("invalid range format")
+	// Responses outside the public scope must not be stored by shared caches
+	if accessScope(c) != ScopePublic {
+		header := c.Writer.Header()
+		header.Set("Cache-Control", privateCacheControl(header.Get("Cache-Control")))
 	}
-
-	var start, end int64
-	var err error
-
-	if parts[0] == "" {
-		// Suffix range: bytes=-500 (last 500 bytes)
-		suffix, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			return 0, 0, fmt.Errorf("invalid suffix range")
-		}
-		start = fileSize - suffix
-		if start < 0 {
-			start = 0
-		}
-		end = fileSize - 1
-	} else {
-		start, err = strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			return 0, 0, fmt.Errorf("invalid start position")
-		}
-
-		if parts[1] == "" {
-			// Open-ended range: bytes=500-
-			end = fileSize - 1
-		} else {
-			end, err = strconv.ParseInt(parts[1], 10, 64)
-			if err != nil {
-				return 0, 0, fmt.Errorf("invalid end position")
-			}
-		}
-	}
-
-	// Validate range
-	if start < 0 || start >= fileSize || end < start || end >= fileSize {
-		return 0, 0, fmt.Errorf("range out of bounds")
-	}
-
-	return start, end, nil
 }
 
-// copyBufferWithLimit copies data with a buffer up to a limit
-func copyBufferWithLimit(dst gin.ResponseWriter, src interface{ Read([]byte) (int, error) }, buf []byte, limit int64) (int64, error) {
-	var written int64
-	for written < limit {
-		toRead := int64(len(buf))
-		if remaining := limit - written; remaining < toRead {
-			toRead = remaining
-		}
+// storeInCache caches a body in Redis asynchronously with the Redis TTL of the object's cache policy,
+// tagged with the ETag of the version it was read from
+func (ctrl *Controller) storeInCache(cacheKey string, data []byte, objInfo *infra.ObjectInfo) {
+	policy := ctrl.Config.EnvConfig.CachePolicy(objInfo.Bucket, objInfo.Key, objInfo.ContentType)
+	if policy.SkipRedis {
+		return
+	}
 
-		n, err := src.Read(buf[:toRead])
-		if n > 0 {
-			nw, errw := dst.Write(buf[:n])
-			if nw > 0 {
-				written += int64(nw)
-			}
-			if errw != nil {
-				return written, errw
-			}
-		}
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return written, err
+	go func() {
+		if err := ctrl.Repository.SetImage(context.Background(), cacheKey, data, objInfo.ContentType, objInfo.ETag, policy.RedisTTL); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(context.Background(), err, "[GetFile] Failed to cache file: %s", cacheKey)
 		}
-	}
-	return written, nil
+	}()
 }
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// HeadFile answers HEAD requests with the same headers as GetFile but never downloads the object
+func (ctrl *Controller) HeadFile(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	bucket := c.Param("bucket")
+	path := c.Param("path")
+
+	// Validate parameters
+	if bucket == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[HeadFile] Missing bucket parameter")
+		utils.JSON400(c, "missing bucket parameter")
+		return
+	}
+
+	// Clean path - remove leading slash, directory-style paths map to the index document in static-site mode
+	key := strings.TrimPrefix(path, "/")
+	website := ctrl.websiteConfig(ctx, bucket)
+	key = resolveIndexDocument(key, website)
+	if key == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[HeadFile] Invalid file path")
+		utils.JSON400(c, "invalid file path")
+		return
+	}
+
+	minioClient, ok := ctrl.resolveMinioClient(c, ctx, bucket, key)
+	if !ok {
+		return
+	}
+
+	cacheKey := scopedCacheKey(c, bucket, key)
+	objInfo, fromCache := ctrl.lookupObjectMeta(ctx, minioClient, cacheKey)
+	if !fromCache {
+		var err error
+		objInfo, err = minioClient.HeadObject(ctx, bucket, key)
+		if err != nil {
+			// Static-site buckets answer like GET would, with the SPA fallback or error page headers
+			if website != nil && ctrl.serveWebsiteFallback(c, ctx, minioClient, bucket, key, website, err) {
+				return
+			}
+			// Check if it's an Access Denied error
+			if infra.IsAccessDeniedError(err) {
+				ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[HeadFile] Access denied for bucket=%s, key=%s", bucket, key)
+				utils.JSON403(c, "Access Denied")
+				return
+			}
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[HeadFile] HEAD request failed for bucket=%s, key=%s", bucket, key)
+			utils.JSON404(c, "file not found")
+			return
+		}
+
+		// Cache metadata for future HEAD requests (async, don't block response)
+		if minioClient == ctrl.Infra.MinioClient {
+			go func() {
+				if err := ctrl.Repository.SetObjectMeta(context.Background(), cacheKey, objInfo); err != nil {
+					ctrl.Provider.LoggerProvider.ErrorWithContextf(context.Background(), err, "[HeadFile] Failed to cache metadata: %s", cacheKey)
+				}
+			}()
+		}
+	}
+
+	if ctrl.checkNotModified(c, objInfo) {
+		return
+	}
+
+	setContentDisposition(c, key, objInfo)
+
+	// Same negotiation as GetFile, so HEAD announces the Vary, encoding and ETag a GET would send
+	encoding := selectEncoding(c, objInfo)
+	if encoding != "" {
+		if _, siblingInfo := ctrl.findPrecompressedSibling(c, ctx, minioClient, bucket, key, objInfo); siblingInfo != nil {
+			objInfo, encoding = siblingInfo, ""
+		}
+	}
+
+	ctrl.writeObjectHeaders(c, objInfo, encoding, fromCache)
+	c.Status(http.StatusOK)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[HeadFile] Served metadata: bucket=%s, key=%s, size=%d, encoding=%s, from_cache=%t", bucket, key, objInfo.Size, encoding, fromCache)
+}
+
+// refreshObjectMeta replaces cached metadata that no longer matches the object a GET just saw in MinIO,
+// so HEAD doesn't keep reporting the ETag and size of an overwritten object
+func (ctrl *Controller) refreshObjectMeta(c *gin.Context, minioClient *infra.MinioClient, bucket, key string, objInfo *infra.ObjectInfo) {
+	if minioClient != ctrl.Infra.MinioClient {
+		return
+	}
+
+	cacheKey := scopedCacheKey(c, bucket, key)
+	go func() {
+		ctx := context.Background()
+		etag, err := ctrl.Repository.GetObjectMetaETag(ctx, cacheKey)
+		if err != nil || etag == objInfo.ETag {
+			// Nothing cached, or still current
+			return
+		}
+		if err := ctrl.Repository.SetObjectMeta(ctx, cacheKey, objInfo); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to refresh cached metadata: %s", cacheKey)
+		}
+	}()
+}
+
+// writeObjectHeaders sets the headers of a full-object response without sending a body. A non-empty
+// encoding describes the body GET would compress on the fly, its length isn't known without compressing.
+func (ctrl *Controller) writeObjectHeaders(c *gin.Context, objInfo *infra.ObjectInfo, encoding string, fromCache bool) {
+	c.Header("Content-Type", objInfo.ContentType)
+	c.Header("Accept-Ranges", "bytes")
+	setValidatorHeaders(c, objInfo)
+	if encoding != "" {
+		c.Header("Content-Encoding", encoding)
+		c.Header("ETag", variantETag(objInfo.ETag, encoding))
+	} else {
+		c.Header("Content-Length", strconv.FormatInt(objInfo.Size, 10))
+		if objInfo.ContentEncoding != "" {
+			c.Header("Content-Encoding", objInfo.ContentEncoding)
+		}
+	}
+	ctrl.setCacheHeaders(c, objInfo, fromCache)
+}
+
+// lookupObjectMeta returns metadata cached in Redis. Cached entries are only trusted for the default
+// client, requests with custom credentials always go to MinIO so access is still checked.
+func (ctrl *Controller) lookupObjectMeta(ctx context.Context, minioClient *infra.MinioClient, cacheKey string) (*infra.ObjectInfo, bool) {
+	if minioClient != ctrl.Infra.MinioClient {
+		return nil, false
+	}
+
+	objInfo, err := ctrl.Repository.GetObjectMeta(ctx, cacheKey)
+	if err != nil {
+		return nil, false
+	}
+	return objInfo, true
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+// HEAD must describe the body GET would send for the same request
+func TestWriteObjectHeadersEncoding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := newScopeTestController(t)
+
+	tests := []struct {
+		name           string
+		objInfo        infra.ObjectInfo
+		acceptEncoding string
+		encoding       string
+		length         string
+		etag           string
+		vary           bool
+	}{
+		{
+			name:           "compressible text encoded on the fly",
+			objInfo:        infra.ObjectInfo{ContentType: "text/css", Size: 4096, ETag: "abc"},
+			acceptEncoding: "gzip",
+			encoding:       EncodingGzip, etag: `W/"abc-gzip"`, vary: true,
+		},
+		{
+			name:    "compressible text without Accept-Encoding",
+			objInfo: infra.ObjectInfo{ContentType: "text/css", Size: 4096, ETag: "abc"},
+			length:  "4096", etag: `"abc"`, vary: true,
+		},
+		{
+			name:           "small text is sent as is",
+			objInfo:        infra.ObjectInfo{ContentType: "text/css", Size: 100, ETag: "abc"},
+			acceptEncoding: "gzip",
+			length:         "100", etag: `"abc"`, vary: true,
+		},
+		{
+			name:           "object stored compressed",
+			objInfo:        infra.ObjectInfo{ContentType: "application/javascript", ContentEncoding: "br", Size: 4096, ETag: "abc"},
+			acceptEncoding: "br",
+			encoding:       "br", length: "4096", etag: `"abc"`, vary: true,
+		},
+		{
+			name:           "image",
+			objInfo:        infra.ObjectInfo{ContentType: "image/png", Size: 4096, ETag: "abc"},
+			acceptEncoding: "gzip",
+			length:         "4096", etag: `"abc"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodHead, "/media/a", nil)
+			c.Request.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			ctrl.writeObjectHeaders(c, &tt.objInfo, selectEncoding(c, &tt.objInfo), false)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := w.Header().Get("Content-Length"); got != tt.length {
+				t.Errorf("Content-Length = %q, want %q", got, tt.length)
+			}
+			if got := w.Header().Get("ETag"); got != tt.etag {
+				t.Errorf("ETag = %q, want %q", got, tt.etag)
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.vary {
+				t.Errorf("Vary = %q, want Accept-Encoding: %t", w.Header().Get("Vary"), tt.vary)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// MaxPlaceholderSize limits hotlink placeholder images, they are buffered in memory
+const MaxPlaceholderSize = 1024 * 1024
+
+// ServeHotlinkPlaceholder answers a hotlinked request with a placeholder image. It is sent with
+// no-store so browsers don't keep it under the real object's URL.
+func (ctrl *Controller) ServeHotlinkPlaceholder(c *gin.Context, bucket, key string) {
+	ctx := c.Request.Context()
+
+	// The placeholder is read with the server's credentials, it is cached in the scope of its own bucket
+	ctrl.setAccessScope(c, bucket, "", "")
+	cacheKey := scopedCacheKey(c, bucket, key)
+
+	objInfo, err := ctrl.Infra.MinioClient.HeadObject(ctx, bucket, key)
+	if err == nil && objInfo.Size > MaxPlaceholderSize {
+		err = fmt.Errorf("placeholder too large (%d bytes > %d limit)", objInfo.Size, MaxPlaceholderSize)
+	}
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Hotlink] Placeholder not available: bucket=%s, key=%s, error=%v", bucket, key, err)
+		utils.JSON403(c, "hotlinking is not allowed")
+		return
+	}
+
+	// HEAD only needs the headers, the stat above already holds them
+	if c.Request.Method == http.MethodHead {
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Type", objInfo.ContentType)
+		c.Header("Content-Length", strconv.FormatInt(objInfo.Size, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	data, _, err := ctrl.Repository.GetImage(ctx, cacheKey, objInfo.ETag)
+	if err != nil || len(data) == 0 {
+		reader, _, err := ctrl.Infra.MinioClient.GetObjectStream(ctx, bucket, key, minio.GetObjectOptions{})
+		if err == nil {
+			data, err = io.ReadAll(io.LimitReader(reader, MaxPlaceholderSize))
+			reader.Close()
+		}
+		if err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Hotlink] Placeholder not available: bucket=%s, key=%s, error=%v", bucket, key, err)
+			utils.JSON403(c, "hotlinking is not allowed")
+			return
+		}
+		ctrl.storeInCache(cacheKey, data, objInfo)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, objInfo.ContentType, data)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Hotlink] Served placeholder: bucket=%s, key=%s, referer=%s", bucket, key, c.GetHeader("Referer"))
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/middlewares"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+const (
+	// DefaultListLimit is the page size when ?limit is not given
+	DefaultListLimit = 100
+	// prefixCursorSuffix sorts after every key below a common prefix, so the next page skips the whole folder
+	prefixCursorSuffix = "\U0010FFFF"
+)
+
+// ListFiles serves GET /:bucket/?list&prefix=...&cursor=...&limit=...&recursive=true as JSON.
+// Listing is opt-in per bucket through LISTING_BUCKETS.
+func (ctrl *Controller) ListFiles(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket := c.Param("bucket")
+
+	if bucket == "" {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[ListFiles] Missing bucket parameter")
+		utils.JSON400(c, "missing bucket parameter")
+		return
+	}
+
+	if !ctrl.Config.EnvConfig.ListingEnabled(bucket) {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[ListFiles] Listing disabled for bucket=%s", bucket)
+		utils.JSON403(c, "listing is disabled for this bucket")
+		return
+	}
+
+	// /:bucket/folder/?list and ?prefix=folder/ are equivalent and may be combined
+	prefix := strings.TrimPrefix(c.Param("path"), "/") + c.Query("prefix")
+	recursive := c.Query("recursive") == "true"
+
+	limit := DefaultListLimit
+	if rawLimit := c.Query("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed <= 0 {
+			utils.JSON400(c, "invalid limit")
+			return
+		}
+		limit = parsed
+	}
+	if limit > ctrl.Config.EnvConfig.Listing.MaxLimit {
+		limit = ctrl.Config.EnvConfig.Listing.MaxLimit
+	}
+
+	startAfter, err := decodeListCursor(c.Query("cursor"))
+	if err != nil {
+		utils.JSON400(c, "invalid cursor")
+		return
+	}
+
+	minioClient, ok := ctrl.resolveMinioClient(c, ctx, bucket, prefix)
+	if !ok {
+		return
+	}
+
+	entries, truncated, err := minioClient.ListObjectsPage(ctx, bucket, prefix, startAfter, recursive, limit)
+	if err != nil {
+		if infra.IsAccessDeniedError(err) {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[ListFiles] Access denied for bucket=%s, prefix=%s", bucket, prefix)
+			utils.JSON403(c, "Access Denied")
+			return
+		}
+		if infra.IsNotFoundError(err) {
+			utils.JSON404(c, "bucket not found")
+			return
+		}
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[ListFiles] Listing failed for bucket=%s, prefix=%s", bucket, prefix)
+		utils.JSON500(c, "failed to list files")
+		return
+	}
+
+	folders := make([]gin.H, 0)
+	objects := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsPrefix {
+			folders = append(folders, gin.H{"prefix": entry.Key})
+			continue
+		}
+
+		object := gin.H{
+			"key":          entry.Key,
+			"size":         entry.Size,
+			"etag":         entry.ETag,
+			"content_type": entry.ContentType,
+		}
+		if !entry.LastModified.IsZero() {
+			object["last_modified"] = entry.LastModified.UTC().Format(http.TimeFormat)
+		}
+		objects = append(objects, object)
+	}
+
+	nextCursor := ""
+	if truncated && len(entries) > 0 {
+		nextCursor = encodeListCursor(entries[len(entries)-1])
+	}
+
+	// Report the bucket under its public name so aliases don't reveal the storage layout
+	publicBucket := c.GetString(middlewares.PublicBucketKey)
+	if publicBucket == "" {
+		publicBucket = bucket
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	utils.JSON200(c, gin.H{
+		"bucket":      publicBucket,
+		"prefix":      prefix,
+		"recursive":   recursive,
+		"folders":     folders,
+		"objects":     objects,
+		"truncated":   truncated,
+		"next_cursor": nextCursor,
+	})
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[ListFiles] Listed bucket=%s, prefix=%s, entries=%d, truncated=%t", bucket, prefix, len(entries), truncated)
+}
+
+// encodeListCursor returns an opaque cursor resuming the listing after entry
+func encodeListCursor(entry infra.ListEntry) string {
+	startAfter := entry.Key
+	if entry.IsPrefix {
+		startAfter += prefixCursorSuffix
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(startAfter))
+}
+
+// decodeListCursor turns a cursor from encodeListCursor back into a StartAfter key
+func decodeListCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	startAfter, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	return string(startAfter), nil
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+func TestListCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry infra.ListEntry
+		want  string
+	}{
+		{"object", infra.ListEntry{Key: "photos/a.jpg"}, "photos/a.jpg"},
+		{"folder skips its contents", infra.ListEntry{Key: "photos/2024/", IsPrefix: true}, "photos/2024/" + prefixCursorSuffix},
+		{"unicode key", infra.ListEntry{Key: "ảnh/đẹp.png"}, "ảnh/đẹp.png"},
+		{"key with url characters", infra.ListEntry{Key: "a b/c?d=e&f+g"}, "a b/c?d=e&f+g"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := encodeListCursor(tt.entry)
+			for _, ch := range cursor {
+				if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_') {
+					t.Fatalf("cursor %q is not URL safe", cursor)
+				}
+			}
+
+			got, err := decodeListCursor(cursor)
+			if err != nil {
+				t.Fatalf("decodeListCursor(%q): %v", cursor, err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeListCursor(encodeListCursor(%+v)) = %q, want %q", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeListCursor(t *testing.T) {
+	if got, err := decodeListCursor(""); err != nil || got != "" {
+		t.Errorf(`decodeListCursor("") = %q, %v, want first page`, got, err)
+	}
+
+	for _, cursor := range []string{"not base64!", "cGhvdG9z=", "a+b/"} {
+		if got, err := decodeListCursor(cursor); err == nil {
+			t.Errorf("decodeListCursor(%q) = %q, want error", cursor, got)
+		}
+	}
+}
+
+func TestPrefixCursorSortsAfterFolderContents(t *testing.T) {
+	folder := "photos/2024/"
+	startAfter := folder + prefixCursorSuffix
+
+	keys := []string{
+		"photos/2024/a.jpg",
+		"photos/2024/zzz/deep.jpg",
+		"photos/2024/ảnh.jpg",
+		"photos/2024/" + "\U0010FFFD" + ".jpg",
+		startAfter,
+		"photos/2024a.jpg",
+		"photos/2025/a.jpg",
+	}
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		if key == startAfter {
+			for _, after := range keys[i+1:] {
+				if len(after) >= len(folder) && after[:len(folder)] == folder {
+					t.Errorf("%q sorts after the folder cursor", after)
+				}
+			}
+			return
+		}
+	}
+}
@@ -12,6 +12,8 @@ type Controller struct {
 	Infra      *infra.Infra
 	Repository *repository.Repository
 	Provider   *provider.Provider
+
+	bandwidth *bandwidthLimiter
 }
 
 func NewController(cfg *config.Config, infra *infra.Infra) *Controller {
@@ -22,5 +24,6 @@ func NewController(cfg *config.Config, infra *infra.Infra) *Controller {
 		Infra:      infra,
 		Repository: newRepository,
 		Provider:   provide,
+		bandwidth:  newBandwidthLimiter(),
 	}
 }
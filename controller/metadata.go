@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+const userMetadataPrefix = "X-Amz-Meta-"
+
+// setMetadataHeaders forwards object metadata allowlisted in EXPOSED_METADATA_HEADERS.
+// Cached and origin responses both go through here, so they carry the same headers.
+func (ctrl *Controller) setMetadataHeaders(c *gin.Context, objInfo *infra.ObjectInfo) {
+	header := c.Writer.Header()
+
+	for _, name := range ctrl.Config.EnvConfig.Metadata.ExposedHeaders {
+		name = http.CanonicalHeaderKey(name)
+
+		switch name {
+		case "Cache-Control":
+			if objInfo.CacheControl != "" {
+				// Object level policy replaces the global one, including its no-cache fallbacks
+				header.Del("Pragma")
+				header.Del("Expires")
+				header.Set("Cache-Control", objInfo.CacheControl)
+			}
+		case "Content-Language":
+			if objInfo.ContentLanguage != "" {
+				header.Set("Content-Language", objInfo.ContentLanguage)
+			}
+		case "Content-Disposition":
+			// Download mode (?download, ?filename=) wins over the stored disposition
+			if objInfo.ContentDisposition != "" && header.Get("Content-Disposition") == "" {
+				header.Set("Content-Disposition", objInfo.ContentDisposition)
+			}
+		case "Expires":
+			if objInfo.Expires != "" {
+				header.Set("Expires", objInfo.Expires)
+			}
+		default:
+			if !strings.HasPrefix(name, userMetadataPrefix) {
+				continue
+			}
+
+			field := strings.TrimPrefix(name, userMetadataPrefix)
+			if field == "*" {
+				for key, value := range objInfo.UserMetadata {
+					header.Set(userMetadataPrefix+key, value)
+				}
+				continue
+			}
+			if value, ok := objInfo.UserMetadata[field]; ok {
+				header.Set(name, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OptionsFile answers OPTIONS requests that are not CORS preflights (those are handled by CORSMiddleware)
+func (ctrl *Controller) OptionsFile(c *gin.Context) {
+	c.Header("Allow", "GET, HEAD, OPTIONS")
+	c.Status(http.StatusNoContent)
+}
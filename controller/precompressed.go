@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+// precompressedExtensions maps content encodings to the suffix of their precompressed sibling objects
+var precompressedExtensions = map[string]string{
+	EncodingBrotli: ".br",
+	EncodingGzip:   ".gz",
+}
+
+// sourceETagMetadataKey is the user metadata field (x-amz-meta-source-etag) a precompressed sibling carries
+// with the ETag of the object it was compressed from
+const sourceETagMetadataKey = "Source-Etag"
+
+// findPrecompressedSibling looks for a precompressed sibling (key.br, key.gz) in the client's preferred encoding.
+// The returned info describes the sibling's body but carries the original Content-Type and validators, with
+// the sibling's Content-Encoding and an ETag derived from the original so revalidation keeps working.
+// Lookups, including misses, are cached in Redis per version of the original, so absent siblings cost no extra
+// MinIO round-trip and a new upload of the original looks for its siblings again.
+func (ctrl *Controller) findPrecompressedSibling(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key string, objInfo *infra.ObjectInfo) (string, *infra.ObjectInfo) {
+	candidates := []string{EncodingBrotli, EncodingGzip}
+	acceptEncoding := c.GetHeader("Accept-Encoding")
+
+	for len(candidates) > 0 {
+		encoding := negotiateEncoding(acceptEncoding, candidates)
+		if encoding == "" {
+			return "", nil
+		}
+		candidates = removeEncoding(candidates, encoding)
+
+		siblingKey := key + precompressedExtensions[encoding]
+		siblingInfo := ctrl.lookupSibling(ctx, minioClient, bucket, siblingKey, scopedCacheKey(c, bucket, siblingKey), objInfo.ETag)
+		if siblingInfo == nil {
+			continue
+		}
+
+		// Cache rules and headers follow the original object, only the body comes from the sibling
+		served := *siblingInfo
+		served.Key = objInfo.Key
+		served.ContentType = objInfo.ContentType
+		served.ContentEncoding = encoding
+		served.ETag = variantETag(objInfo.ETag, encoding)
+		served.LastModified = objInfo.LastModified
+		return siblingKey, &served
+	}
+
+	return "", nil
+}
+
+// lookupSibling returns the metadata of a sibling object compressed from the sourceETag version of the original,
+// or nil when there is none
+func (ctrl *Controller) lookupSibling(ctx context.Context, minioClient *infra.MinioClient, bucket, siblingKey, cacheKey, sourceETag string) *infra.ObjectInfo {
+	cacheable := minioClient == ctrl.Infra.MinioClient
+
+	if cacheable {
+		if ctrl.Repository.IsMissing(ctx, cacheKey, sourceETag) {
+			return nil
+		}
+		// Cached metadata of a sibling from another build is refreshed, it may have been replaced since
+		if siblingInfo, ok := ctrl.lookupObjectMeta(ctx, minioClient, cacheKey); ok && siblingMatches(siblingInfo, sourceETag) {
+			return siblingInfo
+		}
+	}
+
+	siblingInfo, err := minioClient.HeadObject(ctx, bucket, siblingKey)
+	if err != nil {
+		// Only a definite miss is cached, access errors and outages are retried next time
+		if cacheable && infra.IsNotFoundError(err) {
+			ctrl.setSiblingMissing(ctx, cacheKey, sourceETag)
+		}
+		return nil
+	}
+
+	if cacheable {
+		if err := ctrl.Repository.SetObjectMeta(ctx, cacheKey, siblingInfo); err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to cache sibling metadata: %s", cacheKey)
+		}
+	}
+
+	// A sibling compressed from another version would serve outdated content under the original's validators
+	if !siblingMatches(siblingInfo, sourceETag) {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Ignoring precompressed sibling of another version: bucket=%s, key=%s", bucket, siblingKey)
+		if cacheable {
+			ctrl.setSiblingMissing(ctx, cacheKey, sourceETag)
+		}
+		return nil
+	}
+	return siblingInfo
+}
+
+// setSiblingMissing records that the sourceETag version of the original has no usable sibling under cacheKey
+func (ctrl *Controller) setSiblingMissing(ctx context.Context, cacheKey, sourceETag string) {
+	if err := ctrl.Repository.SetMissing(ctx, cacheKey, sourceETag); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to cache sibling miss: %s", cacheKey)
+	}
+}
+
+// siblingMatches reports whether siblingInfo was compressed from the sourceETag version of the original
+func siblingMatches(siblingInfo *infra.ObjectInfo, sourceETag string) bool {
+	source := opaqueETag(siblingInfo.UserMetadata[sourceETagMetadataKey])
+	return source != "" && source == opaqueETag(sourceETag)
+}
+
+// removeEncoding returns encodings without the given one
+func removeEncoding(encodings []string, encoding string) []string {
+	result := make([]string, 0, len(encodings))
+	for _, e := range encodings {
+		if e != encoding {
+			result = append(result, e)
+		}
+	}
+	return result
+}
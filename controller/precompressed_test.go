@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+func TestSiblingMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		metadata   map[string]string
+		sourceETag string
+		want       bool
+	}{
+		{"same version", map[string]string{"Source-Etag": "abc"}, "abc", true},
+		{"quoted ETags", map[string]string{"Source-Etag": `"abc"`}, `"abc"`, true},
+		{"quoted on one side only", map[string]string{"Source-Etag": "abc"}, `"abc"`, true},
+		{"other version", map[string]string{"Source-Etag": "abc"}, "def", false},
+		{"no source recorded", map[string]string{"Filename": "app.js"}, "abc", false},
+		{"no metadata", nil, "abc", false},
+		{"original without ETag", map[string]string{"Source-Etag": ""}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sibling := &infra.ObjectInfo{Key: "app.js.br", UserMetadata: tt.metadata}
+			if got := siblingMatches(sibling, tt.sourceETag); got != tt.want {
+				t.Errorf("siblingMatches(%v, %q) = %t, want %t", tt.metadata, tt.sourceETag, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,392 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+const (
+	// MaxRangesPerRequest caps the number of ranges (after merging) served in one multipart/byteranges response
+	MaxRangesPerRequest = 16
+)
+
+// rangeOpener opens the inclusive byte range [start, end] of the object being served
+type rangeOpener func(start, end int64) (io.ReadCloser, error)
+
+// httpRange is an inclusive byte range of an object
+type httpRange struct {
+	start int64
+	end   int64
+}
+
+func (r httpRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// handleRangeRequest handles HTTP Range requests for video streaming and resume download
+func (ctrl *Controller) handleRangeRequest(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key string, objInfo *infra.ObjectInfo, rangeHeader string) {
+	// Parse Range header: "bytes=start-end[, start-end...]"
+	ranges, err := parseRangeHeader(rangeHeader, objInfo.Size)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Invalid range header: %s, error: %v", rangeHeader, err)
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", objInfo.Size))
+		c.Status(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	// Range-restricted signed URLs may only read inside their signed range
+	if signed := utils.SignedURLFromContext(c); signed != nil && signed.ByteRange != "" {
+		if !rangesAllowed(ranges, signed.ByteRange, objInfo.Size) {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Range outside signed range: bucket=%s, key=%s, range=%s, signed=%s", bucket, key, rangeHeader, signed.ByteRange)
+			utils.JSON403(c, "range not allowed by signed URL")
+			return
+		}
+	}
+
+	open := func(start, end int64) (io.ReadCloser, error) {
+		reader, _, err := minioClient.GetObjectWithRange(ctx, bucket, key, start, end)
+		return reader, err
+	}
+	fromCache := false
+	if file, ok := ctrl.openDiskCopy(c, bucket, key, objInfo); ok {
+		defer file.Close()
+		open = func(start, end int64) (io.ReadCloser, error) {
+			return io.NopCloser(io.NewSectionReader(file, start, end-start+1)), nil
+		}
+		fromCache = true
+	} else if len(ranges) == 1 && ranges[0].length() == objInfo.Size {
+		// Players often start with bytes=0-, a whole-object range fills the disk cache like a full GET
+		if diskCopy := ctrl.createDiskCopy(c, bucket, key, objInfo); diskCopy != nil {
+			defer ctrl.commitDiskCopy(ctx, diskCopy, bucket, key)
+			openObject := open
+			open = func(start, end int64) (io.ReadCloser, error) {
+				reader, err := openObject(start, end)
+				if err != nil {
+					return nil, err
+				}
+				return newTeeReadCloser(reader, diskCopy), nil
+			}
+		}
+	}
+
+	if len(ranges) == 1 {
+		ctrl.serveSingleRange(c, ctx, open, bucket, key, objInfo, ranges[0], fromCache)
+		return
+	}
+	ctrl.serveMultiRange(c, ctx, open, bucket, key, objInfo, ranges, fromCache)
+}
+
+// serveSingleRange answers with a plain 206 response carrying one Content-Range
+func (ctrl *Controller) serveSingleRange(c *gin.Context, ctx context.Context, open rangeOpener, bucket, key string, objInfo *infra.ObjectInfo, r httpRange, fromCache bool) {
+	contentLength := r.length()
+
+	// Set response headers for partial content
+	c.Header("Content-Type", objInfo.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(contentLength, 10))
+	c.Header("Content-Range", r.contentRange(objInfo.Size))
+	c.Header("Accept-Ranges", "bytes")
+	if objInfo.ContentEncoding != "" {
+		c.Header("Content-Encoding", objInfo.ContentEncoding)
+	}
+	setValidatorHeaders(c, objInfo)
+	ctrl.setCacheHeaders(c, objInfo, fromCache)
+	c.Status(http.StatusPartialContent)
+
+	// Stream range to client
+	reader, err := open(r.start, r.end)
+	if err != nil {
+		// Check if it's an Access Denied error
+		if infra.IsAccessDeniedError(err) {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[GetFile] Access denied for bucket=%s, key=%s", bucket, key)
+			utils.JSON403(c, "Access Denied")
+			return
+		}
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Range request failed: bucket=%s, key=%s, range=%d-%d", bucket, key, r.start, r.end)
+		return
+	}
+	defer reader.Close()
+
+	out, done := ctrl.throttle(c, bucket, c.Writer)
+	defer done()
+
+	buf := make([]byte, infra.StreamBufferSize)
+	written, err := copyBufferWithLimit(out, reader, buf, contentLength)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Range stream failed: bucket=%s, key=%s, written=%d", bucket, key, written)
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Served range request: bucket=%s, key=%s, range=%d-%d, written=%d, from_cache=%t", bucket, key, r.start, r.end, written, fromCache)
+}
+
+// serveMultiRange answers with a multipart/byteranges body, one part per range (RFC 9110 section 14.6)
+func (ctrl *Controller) serveMultiRange(c *gin.Context, ctx context.Context, open rangeOpener, bucket, key string, objInfo *infra.ObjectInfo, ranges []httpRange, fromCache bool) {
+	out, done := ctrl.throttle(c, bucket, c.Writer)
+	defer done()
+
+	mw := multipart.NewWriter(out)
+	contentLength := multipartRangesLength(ranges, objInfo, mw.Boundary())
+
+	c.Header("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	c.Header("Content-Length", strconv.FormatInt(contentLength, 10))
+	c.Header("Accept-Ranges", "bytes")
+	setValidatorHeaders(c, objInfo)
+	ctrl.setCacheHeaders(c, objInfo, fromCache)
+	c.Status(http.StatusPartialContent)
+
+	buf := make([]byte, infra.StreamBufferSize)
+	var written int64
+	for _, r := range ranges {
+		part, err := mw.CreatePart(rangePartHeader(r, objInfo))
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to write multipart header: bucket=%s, key=%s", bucket, key)
+			return
+		}
+
+		reader, err := open(r.start, r.end)
+		if err != nil {
+			// Headers are already sent, the client will see a truncated body
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Range request failed: bucket=%s, key=%s, range=%d-%d", bucket, key, r.start, r.end)
+			return
+		}
+
+		n, err := copyBufferWithLimit(part, reader, buf, r.length())
+		reader.Close()
+		written += n
+		if err != nil {
+			ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Multi-range stream failed: bucket=%s, key=%s, written=%d", bucket, key, written)
+			return
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[GetFile] Failed to close multipart body: bucket=%s, key=%s", bucket, key)
+		return
+	}
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[GetFile] Served multi-range request: bucket=%s, key=%s, ranges=%d, written=%d, from_cache=%t", bucket, key, len(ranges), written, fromCache)
+}
+
+// ifRangeMatches evaluates If-Range (RFC 9110 section 13.1.5). The Range header may only be honored when
+// the validator still identifies the current object; a missing header always matches.
+func ifRangeMatches(r *http.Request, objInfo *infra.ObjectInfo) bool {
+	ifRange := strings.TrimSpace(r.Header.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+
+	// Entity tag form requires strong comparison, weak tags never match
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		if strings.HasPrefix(ifRange, "W/") || objInfo.ETag == "" {
+			return false
+		}
+		return opaqueETag(ifRange) == opaqueETag(objInfo.ETag)
+	}
+
+	// HTTP-date form must be an exact match of Last-Modified
+	if objInfo.LastModified.IsZero() {
+		return false
+	}
+	date, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return objInfo.LastModified.Truncate(time.Second).Equal(date)
+}
+
+// rangePartHeader builds the header of one multipart/byteranges part. The ranges are taken from the stored
+// bytes, so a stored Content-Encoding describes each part rather than the multipart body itself.
+func rangePartHeader(r httpRange, objInfo *infra.ObjectInfo) textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", objInfo.ContentType)
+	header.Set("Content-Range", r.contentRange(objInfo.Size))
+	if objInfo.ContentEncoding != "" {
+		header.Set("Content-Encoding", objInfo.ContentEncoding)
+	}
+	return header
+}
+
+// multipartRangesLength computes the exact size of the multipart/byteranges body so Content-Length can be sent upfront
+func multipartRangesLength(ranges []httpRange, objInfo *infra.ObjectInfo, boundary string) int64 {
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	_ = mw.SetBoundary(boundary)
+
+	var bodyLength int64
+	for _, r := range ranges {
+		_, _ = mw.CreatePart(rangePartHeader(r, objInfo))
+		bodyLength += r.length()
+	}
+	_ = mw.Close()
+
+	return int64(counter) + bodyLength
+}
+
+// countingWriter counts bytes written to it
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// parseRangeHeader parses HTTP Range header and returns the satisfiable ranges, sorted and merged
+func parseRangeHeader(rangeHeader string, fileSize int64) ([]httpRange, error) {
+	// Format: bytes=start-end or bytes=start- or bytes=-suffix, comma separated
+	if !strings.HasPrefix(rangeHeader, "bytes=") {
+		return nil, fmt.Errorf("invalid range format")
+	}
+
+	var ranges []httpRange
+	for _, spec := range strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		r, satisfiable, err := parseRangeSpec(spec, fileSize)
+		if err != nil {
+			return nil, err
+		}
+		if satisfiable {
+			ranges = append(ranges, r)
+		}
+	}
+
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("range out of bounds")
+	}
+
+	ranges = mergeRanges(ranges)
+	if len(ranges) > MaxRangesPerRequest {
+		return nil, fmt.Errorf("too many ranges (%d > %d limit)", len(ranges), MaxRangesPerRequest)
+	}
+
+	return ranges, nil
+}
+
+// rangesAllowed reports whether every range lies inside the allowed range spec
+func rangesAllowed(ranges []httpRange, allowedSpec string, fileSize int64) bool {
+	allowed, satisfiable, err := parseRangeSpec(allowedSpec, fileSize)
+	if err != nil || !satisfiable {
+		return false
+	}
+	for _, r := range ranges {
+		if r.start < allowed.start || r.end > allowed.end {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRangeSpec parses a single range spec. Unsatisfiable ranges are reported with satisfiable=false
+// rather than an error so the remaining ranges can still be served.
+func parseRangeSpec(spec string, fileSize int64) (httpRange, bool, error) {
+	parts := strings.Split(spec, "-")
+	if len(parts) != 2 {
+		return httpRange{}, false, fmt.Errorf("invalid range format")
+	}
+
+	if parts[0] == "" {
+		// Suffix range: bytes=-500 (last 500 bytes)
+		suffix, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || suffix < 0 {
+			return httpRange{}, false, fmt.Errorf("invalid suffix range")
+		}
+		if suffix == 0 || fileSize == 0 {
+			return httpRange{}, false, nil
+		}
+		start := fileSize - suffix
+		if start < 0 {
+			start = 0
+		}
+		return httpRange{start: start, end: fileSize - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start < 0 {
+		return httpRange{}, false, fmt.Errorf("invalid start position")
+	}
+
+	end := fileSize - 1
+	if parts[1] != "" {
+		// Closed range: bytes=0-499, an end past the object is clamped to the last byte
+		end, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || end < start {
+			return httpRange{}, false, fmt.Errorf("invalid end position")
+		}
+		if end >= fileSize {
+			end = fileSize - 1
+		}
+	}
+
+	if start >= fileSize {
+		return httpRange{}, false, nil
+	}
+	return httpRange{start: start, end: end}, true, nil
+}
+
+// mergeRanges sorts ranges and coalesces overlapping or adjacent ones
+func mergeRanges(ranges []httpRange) []httpRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end+1 {
+			if r.end > last.end {
+				last.end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// copyBufferWithLimit copies data with a buffer up to a limit
+func copyBufferWithLimit(dst io.Writer, src io.Reader, buf []byte, limit int64) (int64, error) {
+	var written int64
+	for written < limit {
+		toRead := int64(len(buf))
+		if remaining := limit - written; remaining < toRead {
+			toRead = remaining
+		}
+
+		n, err := src.Read(buf[:toRead])
+		if n > 0 {
+			nw, errw := dst.Write(buf[:n])
+			if nw > 0 {
+				written += int64(nw)
+			}
+			if errw != nil {
+				return written, errw
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"bytes"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+func TestParseRangeHeader(t *testing.T) {
+	const size = 1000
+
+	tests := []struct {
+		name    string
+		header  string
+		want    []httpRange
+		wantErr bool
+	}{
+		{name: "closed range", header: "bytes=0-499", want: []httpRange{{0, 499}}},
+		{name: "open range", header: "bytes=500-", want: []httpRange{{500, 999}}},
+		{name: "suffix range", header: "bytes=-100", want: []httpRange{{900, 999}}},
+		{name: "suffix longer than object", header: "bytes=-5000", want: []httpRange{{0, 999}}},
+		{name: "end clamped to object", header: "bytes=900-2000", want: []httpRange{{900, 999}}},
+		{name: "multiple ranges sorted", header: "bytes=500-599, 0-99", want: []httpRange{{0, 99}, {500, 599}}},
+		{name: "overlapping ranges merged", header: "bytes=0-199,100-299", want: []httpRange{{0, 299}}},
+		{name: "adjacent ranges merged", header: "bytes=0-99,100-199", want: []httpRange{{0, 199}}},
+		{name: "contained range merged", header: "bytes=0-499,100-199", want: []httpRange{{0, 499}}},
+		{name: "unsatisfiable range skipped", header: "bytes=0-9,5000-6000", want: []httpRange{{0, 9}}},
+		{name: "empty specs ignored", header: "bytes=0-9,,", want: []httpRange{{0, 9}}},
+		{name: "all ranges unsatisfiable", header: "bytes=1000-1100", wantErr: true},
+		{name: "zero suffix", header: "bytes=-0", wantErr: true},
+		{name: "wrong unit", header: "items=0-9", wantErr: true},
+		{name: "missing dash", header: "bytes=100", wantErr: true},
+		{name: "end before start", header: "bytes=500-100", wantErr: true},
+		{name: "negative start", header: "bytes=--5", wantErr: true},
+		{name: "not a number", header: "bytes=a-b", wantErr: true},
+		{name: "too many ranges", header: "bytes=" + manyRanges(MaxRangesPerRequest+1), wantErr: true},
+		{name: "range limit", header: "bytes=" + manyRanges(MaxRangesPerRequest), want: manyRangesWant(MaxRangesPerRequest)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRangeHeader(tt.header, size)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseRangeHeader(%q) = %v, want error", tt.header, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRangeHeader(%q): %v", tt.header, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRangeHeader(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRangeHeaderEmptyObject(t *testing.T) {
+	if got, err := parseRangeHeader("bytes=0-", 0); err == nil {
+		t.Errorf("range on empty object = %v, want error", got)
+	}
+	if got, err := parseRangeHeader("bytes=-10", 0); err == nil {
+		t.Errorf("suffix range on empty object = %v, want error", got)
+	}
+}
+
+func TestMultipartRangesLength(t *testing.T) {
+	ranges := []httpRange{{0, 99}, {500, 599}, {900, 999}}
+
+	for _, objInfo := range []*infra.ObjectInfo{
+		{Size: 1000, ContentType: "video/mp4"},
+		{Size: 1000, ContentType: "application/javascript", ContentEncoding: "br"},
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for _, r := range ranges {
+			part, err := mw.CreatePart(rangePartHeader(r, objInfo))
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write(bytes.Repeat([]byte("x"), int(r.length())))
+		}
+		mw.Close()
+
+		if got := multipartRangesLength(ranges, objInfo, mw.Boundary()); got != int64(body.Len()) {
+			t.Errorf("%s: multipartRangesLength = %d, actual body is %d bytes", objInfo.ContentType, got, body.Len())
+		}
+	}
+}
+
+func TestRangePartHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		objInfo  infra.ObjectInfo
+		encoding string
+	}{
+		{"identity", infra.ObjectInfo{Size: 1000, ContentType: "video/mp4"}, ""},
+		{"stored compressed", infra.ObjectInfo{Size: 1000, ContentType: "application/javascript", ContentEncoding: "br"}, "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := rangePartHeader(httpRange{100, 199}, &tt.objInfo)
+			if got := header.Get("Content-Type"); got != tt.objInfo.ContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.objInfo.ContentType)
+			}
+			if got := header.Get("Content-Range"); got != "bytes 100-199/1000" {
+				t.Errorf("Content-Range = %q", got)
+			}
+			if got := header.Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestCopyBufferWithLimit(t *testing.T) {
+	var dst bytes.Buffer
+	src := strings.NewReader(strings.Repeat("a", 100))
+
+	written, err := copyBufferWithLimit(&dst, src, make([]byte, 7), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 50 || dst.Len() != 50 {
+		t.Errorf("copied %d bytes (%d in dst), want 50", written, dst.Len())
+	}
+}
+
+// manyRanges builds n disjoint ranges, every other 10 bytes
+func manyRanges(n int) string {
+	specs := make([]string, n)
+	for i := range specs {
+		start := i * 20
+		specs[i] = strconv.Itoa(start) + "-" + strconv.Itoa(start+9)
+	}
+	return strings.Join(specs, ",")
+}
+
+func manyRangesWant(n int) []httpRange {
+	ranges := make([]httpRange, n)
+	for i := range ranges {
+		ranges[i] = httpRange{int64(i * 20), int64(i*20 + 9)}
+	}
+	return ranges
+}
+
+func TestRangesAllowed(t *testing.T) {
+	const size = 1000
+
+	tests := []struct {
+		name    string
+		ranges  []httpRange
+		allowed string
+		want    bool
+	}{
+		{"inside closed range", []httpRange{{0, 99}}, "0-99", true},
+		{"past the signed end", []httpRange{{0, 100}}, "0-99", false},
+		{"before the signed start", []httpRange{{0, 10}}, "5-99", false},
+		{"inside open range", []httpRange{{500, 999}}, "500-", true},
+		{"inside suffix range", []httpRange{{950, 999}}, "-100", true},
+		{"one range outside", []httpRange{{0, 9}, {200, 299}}, "0-99", false},
+		{"invalid signed range", []httpRange{{0, 9}}, "abc", false},
+		{"unsatisfiable signed range", []httpRange{{0, 9}}, "2000-", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rangesAllowed(tt.ranges, tt.allowed, size); got != tt.want {
+				t.Errorf("rangesAllowed(%v, %q) = %t, want %t", tt.ranges, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
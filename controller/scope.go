@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// Access scopes of cached entries. Custom credentials get their own "cred-<hash>" scope.
+const (
+	ScopePublic = "public"
+	// ScopePrivate covers objects the CDN reads with its own credentials on behalf of an authorized
+	// requester (JWT, signed URL or allow-listed address); the middlewares check access before the
+	// cache is consulted
+	ScopePrivate = "private"
+)
+
+// accessScopeKey is the gin context key holding the access scope resolved for the request
+const accessScopeKey = "access_scope"
+
+// setAccessScope records the cache scope of the request, called once the MinIO client is known
+func (ctrl *Controller) setAccessScope(c *gin.Context, bucket, accessKey, secretKey string) {
+	scope := ScopePublic
+	switch {
+	case accessKey != "" && secretKey != "":
+		scope = "cred-" + infra.CredentialHash(accessKey, secretKey)
+	case utils.SignedURLFromContext(c) != nil,
+		ctrl.Config.EnvConfig.SignatureRequired(bucket),
+		ctrl.Config.EnvConfig.AuthPolicy(bucket).Mode != config.AuthModePublic,
+		ctrl.Config.EnvConfig.Network.IPFilter.Restricted(bucket):
+		scope = ScopePrivate
+	}
+	c.Set(accessScopeKey, scope)
+}
+
+// accessScope returns the cache scope of the request, public when none was recorded
+func accessScope(c *gin.Context) string {
+	if scope := c.GetString(accessScopeKey); scope != "" {
+		return scope
+	}
+	return ScopePublic
+}
+
+// scopedCacheKey returns the Redis key of an object for the request's scope. Public entries keep the
+// "cdn:<bucket>:<key>" layout, scoped ones live under "cdn@<scope>:" so a hit always matches the scope.
+func scopedCacheKey(c *gin.Context, bucket, key string) string {
+	scope := accessScope(c)
+	if scope == ScopePublic {
+		return fmt.Sprintf("cdn:%s:%s", bucket, key)
+	}
+	return fmt.Sprintf("cdn@%s:%s:%s", scope, bucket, key)
+}
+
+// privateCacheControl rewrites a Cache-Control value so shared caches never store the response
+func privateCacheControl(value string) string {
+	directives := []string{"private"}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		name := strings.ToLower(strings.SplitN(directive, "=", 2)[0])
+		switch name {
+		case "", "public", "private", "s-maxage", "proxy-revalidate":
+			continue
+		}
+		directives = append(directives, directive)
+	}
+	return strings.Join(directives, ", ")
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/infra"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+func newScopeTestController(t *testing.T) *Controller {
+	t.Helper()
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Limit.CacheTime = 3600
+	cfg.EnvConfig.Network.IPFilter = &config.IPFilter{}
+	if err := cfg.EnvConfig.Network.IPFilter.Load([]byte(`{
+		"deny": ["203.0.113.0/24"],
+		"buckets": {"internal": {"allow": ["10.0.0.0/8"]}}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	return &Controller{Config: cfg}
+}
+
+func TestAccessScopeIPFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := newScopeTestController(t)
+
+	tests := []struct {
+		name         string
+		bucket       string
+		scope        string
+		cacheKey     string
+		cacheControl string
+	}{
+		{
+			name:   "public bucket",
+			bucket: "media", scope: ScopePublic,
+			cacheKey: "cdn:media:a.png", cacheControl: "public, max-age=3600",
+		},
+		{
+			// A shared cache would hand allow-listed objects to any client
+			name:   "bucket with an allow list",
+			bucket: "internal", scope: ScopePrivate,
+			cacheKey: "cdn@private:internal:a.png", cacheControl: "private, max-age=3600",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.bucket+"/a.png", nil)
+
+			ctrl.setAccessScope(c, tt.bucket, "", "")
+			if got := accessScope(c); got != tt.scope {
+				t.Errorf("scope = %q, want %q", got, tt.scope)
+			}
+			if got := scopedCacheKey(c, tt.bucket, "a.png"); got != tt.cacheKey {
+				t.Errorf("cache key = %q, want %q", got, tt.cacheKey)
+			}
+
+			ctrl.setCacheHeaders(c, &infra.ObjectInfo{Bucket: tt.bucket, Key: "a.png", ContentType: "image/png"}, false)
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+		})
+	}
+}
+
+func TestAccessScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := newScopeTestController(t)
+	ctrl.Config.EnvConfig.SignedURL.Buckets = []string{"paid"}
+	ctrl.Config.EnvConfig.Auth.Policies = map[string]config.AuthPolicy{
+		"reports": {Mode: config.AuthModeAuthenticated},
+		"tenants": {Mode: config.AuthModeClaims},
+		"assets":  {Mode: config.AuthModePublic},
+	}
+
+	aliceScope := "cred-" + infra.CredentialHash("alice", "alice-secret")
+
+	tests := []struct {
+		name       string
+		bucket     string
+		signed     bool
+		accessKey  string
+		secretKey  string
+		scope      string
+		cacheKey   string
+		privateTTL bool
+	}{
+		{name: "public bucket", bucket: "media", scope: ScopePublic, cacheKey: "cdn:media:a.png"},
+		{name: "explicitly public auth policy", bucket: "assets", scope: ScopePublic, cacheKey: "cdn:assets:a.png"},
+		{name: "signed URL on a public bucket", bucket: "media", signed: true, scope: ScopePrivate, cacheKey: "cdn@private:media:a.png", privateTTL: true},
+		{name: "signature required", bucket: "paid", scope: ScopePrivate, cacheKey: "cdn@private:paid:a.png", privateTTL: true},
+		{name: "authenticated bucket", bucket: "reports", scope: ScopePrivate, cacheKey: "cdn@private:reports:a.png", privateTTL: true},
+		{name: "claims bucket", bucket: "tenants", scope: ScopePrivate, cacheKey: "cdn@private:tenants:a.png", privateTTL: true},
+		{
+			name: "custom credentials", bucket: "media", accessKey: "alice", secretKey: "alice-secret",
+			scope: aliceScope, cacheKey: "cdn@" + aliceScope + ":media:a.png", privateTTL: true,
+		},
+		{
+			// The credential scope keeps objects read with a caller's keys away from the shared private scope
+			name: "custom credentials on a private bucket", bucket: "reports", accessKey: "alice", secretKey: "alice-secret",
+			scope: aliceScope, cacheKey: "cdn@" + aliceScope + ":reports:a.png", privateTTL: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.bucket+"/a.png", nil)
+			if tt.signed {
+				c.Set(utils.SignedURLKey, &utils.SignedURL{KeyID: "2025"})
+			}
+
+			ctrl.setAccessScope(c, tt.bucket, tt.accessKey, tt.secretKey)
+			if got := accessScope(c); got != tt.scope {
+				t.Errorf("scope = %q, want %q", got, tt.scope)
+			}
+			if got := scopedCacheKey(c, tt.bucket, "a.png"); got != tt.cacheKey {
+				t.Errorf("cache key = %q, want %q", got, tt.cacheKey)
+			}
+
+			ctrl.setCacheHeaders(c, &infra.ObjectInfo{Bucket: tt.bucket, Key: "a.png", ContentType: "image/png"}, false)
+			want := "public, max-age=3600"
+			if tt.privateTTL {
+				want = "private, max-age=3600"
+			}
+			if got := w.Header().Get("Cache-Control"); got != want {
+				t.Errorf("Cache-Control = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCredentialScopesAreSeparate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := newScopeTestController(t)
+
+	cacheKey := func(accessKey, secretKey string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/media/a.png", nil)
+		ctrl.setAccessScope(c, "media", accessKey, secretKey)
+		return scopedCacheKey(c, "media", "a.png")
+	}
+
+	keys := map[string]string{
+		"alice":             cacheKey("alice", "alice-secret"),
+		"bob":               cacheKey("bob", "bob-secret"),
+		"alice, old secret": cacheKey("alice", "old-secret"),
+		"default client":    cacheKey("", ""),
+	}
+	seen := map[string]string{}
+	for name, key := range keys {
+		if other, ok := seen[key]; ok {
+			t.Errorf("%s and %s share cache key %q", name, other, key)
+		}
+		seen[key] = name
+	}
+}
+
+func TestAccessScopeDefaultsToPublic(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := accessScope(c); got != ScopePublic {
+		t.Errorf("scope without setAccessScope = %q, want %q", got, ScopePublic)
+	}
+}
+
+func TestPrivateCacheControl(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"public, max-age=3600", "private, max-age=3600"},
+		{"public, max-age=60, s-maxage=3600", "private, max-age=60"},
+		{"max-age=31536000, immutable", "private, max-age=31536000, immutable"},
+		{"Public, Proxy-Revalidate, max-age=10", "private, max-age=10"},
+		{"private, no-cache", "private, no-cache"},
+		{"no-store", "private, no-store"},
+		{"", "private"},
+	}
+
+	for _, tt := range tests {
+		if got := privateCacheControl(tt.value); got != tt.want {
+			t.Errorf("privateCacheControl(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+	"golang.org/x/time/rate"
+)
+
+// IdleLimiterTTL is how long a per-IP or per-bucket limiter is kept after its last stream ended
+const IdleLimiterTTL = 5 * time.Minute
+
+// bandwidthLimiter keeps the shared per-IP and per-bucket token buckets of this replica
+type bandwidthLimiter struct {
+	mu        sync.Mutex
+	ips       map[string]*sharedLimiter
+	buckets   map[string]*sharedLimiter
+	lastSweep time.Time
+}
+
+type sharedLimiter struct {
+	limiter *rate.Limiter
+	// active counts streams using the limiter, lastUsed is when the last one started or ended
+	active   int
+	lastUsed time.Time
+}
+
+func newBandwidthLimiter() *bandwidthLimiter {
+	return &bandwidthLimiter{
+		ips:       map[string]*sharedLimiter{},
+		buckets:   map[string]*sharedLimiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+// acquire returns the shared limiter for name, creating it on first use. The limiter is kept until
+// release is called for every acquire and it has then been idle for IdleLimiterTTL.
+func (b *bandwidthLimiter) acquire(limiters map[string]*sharedLimiter, name string, limit *config.BandwidthLimit) *sharedLimiter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.lastSweep) > IdleLimiterTTL {
+		b.sweep(now)
+	}
+
+	shared, ok := limiters[name]
+	if !ok {
+		shared = &sharedLimiter{limiter: newRateLimiter(limit)}
+		limiters[name] = shared
+	}
+	shared.active++
+	shared.lastUsed = now
+	return shared
+}
+
+// release marks the end of a stream started with acquire
+func (b *bandwidthLimiter) release(shared *sharedLimiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	shared.active--
+	shared.lastUsed = time.Now()
+}
+
+// sweep drops limiters without active streams that have been idle for IdleLimiterTTL, callers hold mu
+func (b *bandwidthLimiter) sweep(now time.Time) {
+	for _, limiters := range []map[string]*sharedLimiter{b.ips, b.buckets} {
+		for name, shared := range limiters {
+			if shared.active == 0 && now.Sub(shared.lastUsed) > IdleLimiterTTL {
+				delete(limiters, name)
+			}
+		}
+	}
+	b.lastSweep = now
+}
+
+func newRateLimiter(limit *config.BandwidthLimit) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(limit.BytesPerSecond), limit.BurstBytes())
+}
+
+// throttle wraps w with the connection, client IP and bucket bandwidth limits that apply to the request.
+// A signed URL tier replaces the connection and IP limits; the bucket limit always applies.
+// The caller must call done once the stream has ended so shared limiters can be dropped when idle.
+func (ctrl *Controller) throttle(c *gin.Context, bucket string, w io.Writer) (io.Writer, func()) {
+	bandwidth := &ctrl.Config.EnvConfig.Bandwidth
+	connectionLimit, ipLimit := bandwidth.Connection, bandwidth.IP
+
+	if signed := utils.SignedURLFromContext(c); signed != nil && signed.Tier != "" {
+		if tier, ok := bandwidth.Tiers[signed.Tier]; ok {
+			connectionLimit, ipLimit = &tier, nil
+		} else {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(c.Request.Context(), "[GetFile] Unknown bandwidth tier: %s", signed.Tier)
+		}
+	}
+
+	var limiters []*rate.Limiter
+	var shared []*sharedLimiter
+	if connectionLimit.IsEnabled() {
+		limiters = append(limiters, newRateLimiter(connectionLimit))
+	}
+	if ipLimit.IsEnabled() {
+		shared = append(shared, ctrl.bandwidth.acquire(ctrl.bandwidth.ips, c.ClientIP(), ipLimit))
+	}
+	if bucketLimit := ctrl.Config.EnvConfig.BucketBandwidth(bucket); bucketLimit.IsEnabled() {
+		shared = append(shared, ctrl.bandwidth.acquire(ctrl.bandwidth.buckets, bucket, bucketLimit))
+	}
+	for _, s := range shared {
+		limiters = append(limiters, s.limiter)
+	}
+
+	done := func() {
+		for _, s := range shared {
+			ctrl.bandwidth.release(s)
+		}
+	}
+
+	if len(limiters) == 0 {
+		return w, done
+	}
+	return &throttledWriter{ctx: c.Request.Context(), w: w, limiters: limiters}, done
+}
+
+// throttledWriter holds writes back until every limiter has tokens for them
+type throttledWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*rate.Limiter
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// WaitN can't ask for more than the burst, larger writes go out in burst-sized chunks
+		chunk := len(p)
+		for _, limiter := range t.limiters {
+			if burst := limiter.Burst(); chunk > burst {
+				chunk = burst
+			}
+		}
+
+		for _, limiter := range t.limiters {
+			if err := limiter.WaitN(t.ctx, chunk); err != nil {
+				return written, err
+			}
+		}
+
+		n, err := t.w.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
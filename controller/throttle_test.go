@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+	"golang.org/x/time/rate"
+)
+
+func TestBandwidthLimiterKeepsActiveLimiters(t *testing.T) {
+	b := newBandwidthLimiter()
+	limit := &config.BandwidthLimit{BytesPerSecond: 1024}
+
+	streaming := b.acquire(b.ips, "10.0.0.1", limit)
+	finished := b.acquire(b.ips, "10.0.0.2", limit)
+	b.release(finished)
+
+	// Both limiters were last touched long ago, only the one without streams may go
+	past := time.Now().Add(-2 * IdleLimiterTTL)
+	streaming.lastUsed, finished.lastUsed = past, past
+	b.sweep(time.Now())
+
+	if b.ips["10.0.0.1"] != streaming {
+		t.Error("limiter with an active stream was swept")
+	}
+	if _, ok := b.ips["10.0.0.2"]; ok {
+		t.Error("idle limiter was kept")
+	}
+
+	// A new stream from the same IP shares the existing limiter
+	if again := b.acquire(b.ips, "10.0.0.1", limit); again != streaming || streaming.active != 2 {
+		t.Errorf("acquire returned a new limiter or active=%d, want shared limiter with active=2", streaming.active)
+	}
+}
+
+func TestThrottleLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bandwidth := config.BandwidthConfig{
+		Connection: &config.BandwidthLimit{BytesPerSecond: 1000},
+		IP:         &config.BandwidthLimit{BytesPerSecond: 2000},
+		Buckets: map[string]config.BandwidthLimit{
+			"videos": {BytesPerSecond: 5000},
+			"open":   {},
+		},
+		Tiers: map[string]config.BandwidthLimit{
+			"premium":   {BytesPerSecond: 4000},
+			"unlimited": {},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		bandwidth config.BandwidthConfig
+		bucket    string
+		tier      string
+		want      []rate.Limit
+	}{
+		{name: "nothing configured", bucket: "videos"},
+		{name: "connection and IP", bandwidth: bandwidth, bucket: "media", want: []rate.Limit{1000, 2000}},
+		{name: "bucket limit added", bandwidth: bandwidth, bucket: "videos", want: []rate.Limit{1000, 2000, 5000}},
+		{name: "zero bucket rate is unlimited", bandwidth: bandwidth, bucket: "open", want: []rate.Limit{1000, 2000}},
+		{name: "tier replaces connection and IP", bandwidth: bandwidth, bucket: "media", tier: "premium", want: []rate.Limit{4000}},
+		{name: "tier keeps the bucket limit", bandwidth: bandwidth, bucket: "videos", tier: "premium", want: []rate.Limit{4000, 5000}},
+		{name: "unlimited tier", bandwidth: bandwidth, bucket: "media", tier: "unlimited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := &Controller{Config: &config.Config{EnvConfig: &config.EnvConfig{Bandwidth: tt.bandwidth}}, bandwidth: newBandwidthLimiter()}
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.bucket+"/a.mp4", nil)
+			if tt.tier != "" {
+				c.Set(utils.SignedURLKey, &utils.SignedURL{KeyID: "2025", Tier: tt.tier})
+			}
+
+			var body bytes.Buffer
+			out, done := ctrl.throttle(c, tt.bucket, &body)
+			defer done()
+
+			var got []rate.Limit
+			if throttled, ok := out.(*throttledWriter); ok {
+				for _, limiter := range throttled.limiters {
+					got = append(got, limiter.Limit())
+				}
+			} else if out != &body {
+				t.Fatalf("throttle returned %T, want the response writer or a throttledWriter", out)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("limits = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleSharesLimiters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Bandwidth.IP = &config.BandwidthLimit{BytesPerSecond: 2000}
+	cfg.EnvConfig.Bandwidth.Buckets = map[string]config.BandwidthLimit{"*": {BytesPerSecond: 5000}}
+	ctrl := &Controller{Config: cfg, bandwidth: newBandwidthLimiter()}
+
+	request := func(clientIP, bucket string) (*throttledWriter, func()) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/"+bucket+"/a.mp4", nil)
+		c.Request.RemoteAddr = clientIP + ":1234"
+		out, done := ctrl.throttle(c, bucket, io.Discard)
+		return out.(*throttledWriter), done
+	}
+
+	first, doneFirst := request("10.0.0.1", "videos")
+	second, doneSecond := request("10.0.0.1", "videos")
+	other, doneOther := request("10.0.0.2", "media")
+
+	if first.limiters[0] != second.limiters[0] || first.limiters[1] != second.limiters[1] {
+		t.Error("connections of the same client and bucket don't share their limiters")
+	}
+	if other.limiters[0] == first.limiters[0] || other.limiters[1] == first.limiters[1] {
+		t.Error("another client or bucket shares a limiter")
+	}
+	if active := ctrl.bandwidth.ips["10.0.0.1"].active; active != 2 {
+		t.Errorf("active streams = %d, want 2", active)
+	}
+
+	doneFirst()
+	doneSecond()
+	doneOther()
+	if active := ctrl.bandwidth.ips["10.0.0.1"].active; active != 0 {
+		t.Errorf("active streams after done = %d, want 0", active)
+	}
+}
+
+func TestThrottledWriterPacesWrites(t *testing.T) {
+	limiter := rate.NewLimiter(64*1024, 16*1024)
+	var sink chunkRecorder
+	w := &throttledWriter{ctx: context.Background(), w: &sink, limiters: []*rate.Limiter{limiter}}
+
+	start := time.Now()
+	n, err := w.Write(make([]byte, 48*1024))
+	elapsed := time.Since(start)
+
+	if n != 48*1024 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	// The burst goes out at once, the remaining 32KB take half a second at 64KB/s
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("48KB written in %s, want about 500ms", elapsed)
+	}
+	if sink.largest > 16*1024 {
+		t.Errorf("largest chunk = %d bytes, want at most the 16KB burst", sink.largest)
+	}
+}
+
+func TestThrottledWriterStopsWithRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter := rate.NewLimiter(1024, 1024)
+	w := &throttledWriter{ctx: ctx, w: io.Discard, limiters: []*rate.Limiter{limiter}}
+
+	cancel()
+	if n, err := w.Write(make([]byte, 4096)); err == nil || n >= 4096 {
+		t.Errorf("Write after the client left = %d, %v, want an error", n, err)
+	}
+}
+
+// chunkRecorder records the size of the largest write it received
+type chunkRecorder struct {
+	largest int
+}
+
+func (r *chunkRecorder) Write(p []byte) (int, error) {
+	if len(p) > r.largest {
+		r.largest = len(p)
+	}
+	return len(p), nil
+}
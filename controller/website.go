@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/infra"
+)
+
+const (
+	// MaxWebsiteMarkerSize limits the size of the JSON marker object read from a bucket
+	MaxWebsiteMarkerSize = 64 * 1024
+	// MaxErrorDocumentSize limits custom 404/403 pages, they are buffered in memory
+	MaxErrorDocumentSize = 1024 * 1024
+)
+
+// websiteConfig returns the static-site settings of a bucket, or nil when static-site mode is off.
+// Settings from WEBSITE_CONFIG win, otherwise the bucket's marker object is read and cached in Redis.
+func (ctrl *Controller) websiteConfig(ctx context.Context, bucket string) *config.WebsiteConfig {
+	if website, ok := ctrl.Config.EnvConfig.Website.Buckets[bucket]; ok {
+		return &website
+	}
+
+	marker := ctrl.Config.EnvConfig.Website.MarkerObject
+	if marker == "" {
+		return nil
+	}
+
+	website, err := ctrl.Repository.GetWebsiteConfig(ctx, bucket)
+	if err != nil {
+		var cacheable bool
+		website, cacheable = ctrl.loadWebsiteMarker(ctx, bucket, marker)
+		if cacheable {
+			if err := ctrl.Repository.SetWebsiteConfig(ctx, bucket, website); err != nil {
+				ctrl.Provider.LoggerProvider.ErrorWithContextf(ctx, err, "[Website] Failed to cache website config: bucket=%s", bucket)
+			}
+		}
+	}
+
+	if website.IsEmpty() {
+		return nil
+	}
+	return website
+}
+
+// loadWebsiteMarker reads the marker object of a bucket. A missing marker yields an empty config that
+// is safe to cache; transient errors are reported as not cacheable.
+func (ctrl *Controller) loadWebsiteMarker(ctx context.Context, bucket, marker string) (*config.WebsiteConfig, bool) {
+	website := &config.WebsiteConfig{}
+
+	data, _, err := ctrl.Infra.MinioClient.GetSmallObject(ctx, bucket, marker, MaxWebsiteMarkerSize)
+	if err != nil {
+		if infra.IsNotFoundError(err) {
+			return website, true
+		}
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Website] Failed to read marker object: bucket=%s, key=%s, error=%v", bucket, marker, err)
+		return website, false
+	}
+
+	if err := json.Unmarshal(data, website); err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Website] Invalid marker object: bucket=%s, key=%s, error=%v", bucket, marker, err)
+		return &config.WebsiteConfig{}, true
+	}
+	return website, true
+}
+
+// resolveIndexDocument maps directory-style keys ("" or ending in "/") to the bucket's index document
+func resolveIndexDocument(key string, website *config.WebsiteConfig) string {
+	if website == nil || website.IndexDocument == "" {
+		return key
+	}
+	if key == "" || strings.HasSuffix(key, "/") {
+		return key + website.IndexDocument
+	}
+	return key
+}
+
+// serveWebsiteFallback answers a failed object lookup with the bucket's SPA fallback or custom error page.
+// Returns false when nothing applies and the caller should send its usual JSON error.
+func (ctrl *Controller) serveWebsiteFallback(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, key string, website *config.WebsiteConfig, lookupErr error) bool {
+	if infra.IsAccessDeniedError(lookupErr) {
+		if website.Error403 == "" {
+			return false
+		}
+		return ctrl.serveWebsiteDocument(c, ctx, minioClient, bucket, website.Error403, http.StatusForbidden)
+	}
+
+	if !infra.IsNotFoundError(lookupErr) {
+		return false
+	}
+
+	if website.SPAFallback != "" && key != website.SPAFallback && wantsSPAFallback(c, key) {
+		if ctrl.serveWebsiteDocument(c, ctx, minioClient, bucket, website.SPAFallback, http.StatusOK) {
+			return true
+		}
+	}
+
+	if website.Error404 != "" && key != website.Error404 {
+		return ctrl.serveWebsiteDocument(c, ctx, minioClient, bucket, website.Error404, http.StatusNotFound)
+	}
+	return false
+}
+
+// wantsSPAFallback reports whether a missing key looks like a client-side route rather than a missing asset
+func wantsSPAFallback(c *gin.Context, key string) bool {
+	return path.Ext(key) == "" || strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// serveWebsiteDocument serves docKey with the given status. 200 responses go through the regular
+// cached path, error pages are sent with no-cache so clients don't hold on to them.
+// HEAD requests get the same status and headers without the document being read.
+func (ctrl *Controller) serveWebsiteDocument(c *gin.Context, ctx context.Context, minioClient *infra.MinioClient, bucket, docKey string, status int) bool {
+	objInfo, err := minioClient.HeadObject(ctx, bucket, docKey)
+	if err != nil {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Website] Document not available: bucket=%s, key=%s, error=%v", bucket, docKey, err)
+		return false
+	}
+
+	head := c.Request.Method == http.MethodHead
+
+	if status == http.StatusOK {
+		if ctrl.checkNotModified(c, objInfo) {
+			return true
+		}
+		if head {
+			ctrl.writeObjectHeaders(c, objInfo, selectEncoding(c, objInfo), false)
+			c.Status(status)
+			return true
+		}
+		ctrl.serveObject(c, ctx, minioClient, bucket, docKey, objInfo, selectEncoding(c, objInfo))
+		return true
+	}
+
+	if objInfo.Size > MaxErrorDocumentSize {
+		ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Website] Error document too large: bucket=%s, key=%s, size=%d", bucket, docKey, objInfo.Size)
+		return false
+	}
+
+	if head {
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Content-Type", objInfo.ContentType)
+		c.Header("Content-Length", strconv.FormatInt(objInfo.Size, 10))
+		c.Status(status)
+		return true
+	}
+
+	cacheKey := scopedCacheKey(c, bucket, docKey)
+	data, _, err := ctrl.Repository.GetImage(ctx, cacheKey, objInfo.ETag)
+	if err != nil || len(data) == 0 {
+		data, _, err = minioClient.GetSmallObject(ctx, bucket, docKey, MaxErrorDocumentSize)
+		if err != nil {
+			ctrl.Provider.LoggerProvider.WarningWithContextf(ctx, "[Website] Failed to read error document: bucket=%s, key=%s, error=%v", bucket, docKey, err)
+			return false
+		}
+		ctrl.storeInCache(cacheKey, data, objInfo)
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(status, objInfo.ContentType, data)
+
+	ctrl.Provider.LoggerProvider.InfoWithContextf(ctx, "[Website] Served error document: bucket=%s, key=%s, status=%d", bucket, docKey, status)
+	return true
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+func TestResolveIndexDocument(t *testing.T) {
+	website := &config.WebsiteConfig{IndexDocument: "index.html"}
+
+	tests := []struct {
+		name    string
+		key     string
+		website *config.WebsiteConfig
+		want    string
+	}{
+		// Keys as VirtualHostMiddleware produces them for "/", "/guide/" and "/guide" under the "site/" prefix
+		{"virtual host root", "", website, "index.html"},
+		{"virtual host root under a prefix", "site/", website, "site/index.html"},
+		{"directory", "site/guide/", website, "site/guide/index.html"},
+		{"object", "site/guide", website, "site/guide"},
+		{"asset", "site/app.js", website, "site/app.js"},
+		{"static-site mode off", "site/", nil, "site/"},
+		{"no index document", "site/", &config.WebsiteConfig{SPAFallback: "index.html"}, "site/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveIndexDocument(tt.key, tt.website); got != tt.want {
+				t.Errorf("resolveIndexDocument(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWantsSPAFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		key    string
+		accept string
+		want   bool
+	}{
+		{"client-side route", "dashboard/settings", "", true},
+		{"missing asset", "static/app.3f2a.js", "*/*", false},
+		{"page navigation to a dotted path", "docs/v1.2", "text/html,application/xhtml+xml", true},
+		{"missing image", "logo.png", "image/avif,image/webp", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.key, nil)
+			c.Request.Header.Set("Accept", tt.accept)
+
+			if got := wantsSPAFallback(c, tt.key); got != tt.want {
+				t.Errorf("wantsSPAFallback(%q, Accept %q) = %t, want %t", tt.key, tt.accept, got, tt.want)
+			}
+		})
+	}
+}
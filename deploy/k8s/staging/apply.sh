@@ -3,4 +3,9 @@
 set -e
 source .env
 sh apply_envsubst.sh
-kubectl --kubeconfig kubeconfig.yaml apply -k ./
\ No newline at end of file
+kubectl --kubeconfig kubeconfig.yaml apply -k ./
+
+# gau-cdn-http-statefulset replaced the gau-cdn-http-deployment Deployment. Both match the service selector, so the old pods
+# (without a disk cache) are removed as soon as the StatefulSet is ready
+kubectl --kubeconfig kubeconfig.yaml -n bao-${DEPLOY_ENV}-env rollout status statefulset/gau-cdn-http-statefulset --timeout=10m
+kubectl --kubeconfig kubeconfig.yaml -n bao-${DEPLOY_ENV}-env delete deployment gau-cdn-http-deployment --ignore-not-found
@@ -5,6 +5,17 @@
 TEMPLATE_DIR="template"
 OUTPUT_DIR="base"
 
+# Mounted config files must hold valid JSON even when the feature is not used
+if [ -z "$REWRITE_RULES" ]; then
+    export REWRITE_RULES='[]'
+fi
+if [ -z "$IP_FILTER" ]; then
+    export IP_FILTER='{}'
+fi
+if [ -z "$JWT_KEYS" ]; then
+    export JWT_KEYS='{"keys": []}'
+fi
+
 # Create the output directory if it doesn't exist
 mkdir -p "$OUTPUT_DIR"
 
@@ -8,8 +8,10 @@ import (
 
 type Infra struct {
 	MinioClient *MinioClient
-	RedisClient *RedisClient
-	Logger      *LoggerClient
+	// MinioClientPool serves requests that carry their own MinIO credentials
+	MinioClientPool *MinioClientPool
+	RedisClient     *RedisClient
+	Logger          *LoggerClient
 }
 
 func InitInfra(cfg *config.Config) *Infra {
@@ -24,9 +26,19 @@ func InitInfra(cfg *config.Config) *Infra {
 	if loggerClient == nil {
 		panic("Failed to create Logger client")
 	}
+
+	minioClientPool, err := NewMinioClientPool(cfg.EnvConfig, cfg.EnvConfig.Minio.ClientPoolSize, cfg.EnvConfig.Minio.ClientIdleTTL)
+	if err != nil {
+		log.Fatalf("Failed to initialize MinIO client pool: %v", err)
+	}
+	if err := minioClientPool.RegisterMetrics(loggerClient.Meter); err != nil {
+		log.Printf("Failed to register MinIO client pool metrics: %v", err)
+	}
+
 	return &Infra{
-		MinioClient: minioClient,
-		RedisClient: redisClient,
-		Logger:      loggerClient,
+		MinioClient:     minioClient,
+		MinioClientPool: minioClientPool,
+		RedisClient:     redisClient,
+		Logger:          loggerClient,
 	}
 }
@@ -2,8 +2,11 @@ package infra
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -21,9 +24,49 @@ type MinioClient struct {
 }
 
 type ObjectInfo struct {
-	Size        int64
-	ContentType string
-	ETag        string
+	Bucket          string
+	Key             string
+	Size            int64
+	ContentType     string
+	ContentEncoding string
+	ETag            string
+	LastModified    time.Time
+
+	// Standard metadata stored with the object, emitted as response headers when allowlisted
+	CacheControl       string
+	ContentLanguage    string
+	ContentDisposition string
+	Expires            string
+
+	UserMetadata map[string]string // x-amz-meta-* values keyed by canonical name without prefix
+}
+
+// newObjectInfo converts MinIO stat results, keeping the metadata we may forward to clients
+func newObjectInfo(bucket string, stat minio.ObjectInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Bucket:             bucket,
+		Key:                stat.Key,
+		Size:               stat.Size,
+		ContentType:        stat.ContentType,
+		ContentEncoding:    stat.Metadata.Get("Content-Encoding"),
+		ETag:               stat.ETag,
+		LastModified:       stat.LastModified,
+		CacheControl:       stat.Metadata.Get("Cache-Control"),
+		ContentLanguage:    stat.Metadata.Get("Content-Language"),
+		ContentDisposition: stat.Metadata.Get("Content-Disposition"),
+		Expires:            stat.Metadata.Get("Expires"),
+		UserMetadata:       stat.UserMetadata,
+	}
+}
+
+// ListEntry is an object or, when IsPrefix is set, a common prefix ("folder") of a listing
+type ListEntry struct {
+	Key          string
+	IsPrefix     bool
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
 }
 
 func NewMinioClient(cfg *appconfig.EnvConfig) (*MinioClient, error) {
@@ -59,34 +102,6 @@ func NewMinioClient(cfg *appconfig.EnvConfig) (*MinioClient, error) {
 	}, nil
 }
 
-// NewMinioClientWithCredentials creates a temporary MinIO client with custom credentials
-func NewMinioClientWithCredentials(cfg *appconfig.EnvConfig, accessKey, secretKey string) (*MinioClient, error) {
-	endpoint := cfg.Minio.Endpoint
-	if endpoint == "" {
-		return nil, fmt.Errorf("MinIO endpoint is not configured")
-	}
-
-	if accessKey == "" || secretKey == "" {
-		return nil, fmt.Errorf("access key and secret key cannot be empty")
-	}
-
-	bucketName := cfg.Minio.BucketName
-	useSSL := cfg.Minio.UseSSL
-
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create MinIO client with custom credentials: %w", err)
-	}
-
-	return &MinioClient{
-		Client:     client,
-		BucketName: bucketName,
-	}, nil
-}
-
 // HeadObject gets object metadata without downloading content (for cache decision)
 func (m *MinioClient) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
 	stat, err := m.Client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
@@ -94,11 +109,50 @@ func (m *MinioClient) HeadObject(ctx context.Context, bucket, key string) (*Obje
 		return nil, fmt.Errorf("failed to stat object: %w", err)
 	}
 
-	return &ObjectInfo{
-		Size:        stat.Size,
-		ContentType: stat.ContentType,
-		ETag:        stat.ETag,
-	}, nil
+	return newObjectInfo(bucket, stat), nil
+}
+
+// ListObjectsPage lists at most limit entries under prefix, starting after startAfter.
+// Non-recursive listings group keys by "/" into common prefixes. Reports whether more entries follow.
+func (m *MinioClient) ListObjectsPage(ctx context.Context, bucket, prefix, startAfter string, recursive bool, limit int) ([]ListEntry, bool, error) {
+	// Cancelling stops the background pagination of ListObjects once the page is full
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts := minio.ListObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    recursive,
+		StartAfter:   startAfter,
+		MaxKeys:      limit + 1,
+		WithMetadata: true,
+	}
+
+	entries := make([]ListEntry, 0, limit)
+	for object := range m.Client.ListObjects(ctx, bucket, opts) {
+		if object.Err != nil {
+			return nil, false, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		if len(entries) == limit {
+			return entries, true, nil
+		}
+
+		contentType := object.ContentType
+		if contentType == "" {
+			// MinIO returns the content type as metadata when WithMetadata is set
+			contentType = object.UserMetadata["content-type"]
+		}
+
+		entries = append(entries, ListEntry{
+			Key:          object.Key,
+			IsPrefix:     !recursive && strings.HasSuffix(object.Key, "/") && object.Size == 0 && object.ETag == "",
+			Size:         object.Size,
+			ContentType:  contentType,
+			ETag:         object.ETag,
+			LastModified: object.LastModified,
+		})
+	}
+
+	return entries, false, nil
 }
 
 // GetObjectStream returns a reader for streaming large files directly to client
@@ -114,13 +168,7 @@ func (m *MinioClient) GetObjectStream(ctx context.Context, bucket, key string, o
 		return nil, nil, fmt.Errorf("failed to stat object: %w", err)
 	}
 
-	info := &ObjectInfo{
-		Size:        stat.Size,
-		ContentType: stat.ContentType,
-		ETag:        stat.ETag,
-	}
-
-	return object, info, nil
+	return object, newObjectInfo(bucket, stat), nil
 }
 
 // GetObjectWithRange supports range requests for video streaming and resume download
@@ -188,10 +236,23 @@ func (m *MinioClient) StreamToWriter(ctx context.Context, bucket, key string, w
 
 // IsAccessDeniedError checks if the error is an access denied error from MinIO
 func IsAccessDeniedError(err error) bool {
-	if err == nil {
-		return false
+	code := errorCode(err)
+	return code == "AccessDenied" ||
+		code == "InvalidAccessKeyId" ||
+		code == "SignatureDoesNotMatch"
+}
+
+// IsNotFoundError checks if the error means the object or bucket does not exist in MinIO
+func IsNotFoundError(err error) bool {
+	code := errorCode(err)
+	return code == "NoSuchKey" || code == "NoSuchBucket" || code == "NotFound"
+}
+
+// errorCode extracts the S3 error code, minio.ToErrorResponse does not look through wrapped errors
+func errorCode(err error) string {
+	var errResp minio.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.Code
 	}
-	return minio.ToErrorResponse(err).Code == "AccessDenied" ||
-		minio.ToErrorResponse(err).Code == "InvalidAccessKeyId" ||
-		minio.ToErrorResponse(err).Code == "SignatureDoesNotMatch"
+	return ""
 }
@@ -0,0 +1,188 @@
+package infra
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	appconfig "github.com/tnqbao/gau-cdn-service/config"
+	metricwrap "go.opentelemetry.io/otel/metric"
+)
+
+// MinioClientPool caches MinIO clients for caller-supplied credentials. Clients are kept in a bounded
+// LRU keyed by a hash of the credentials, dropped after an idle TTL, and share one HTTP transport so
+// connections to MinIO are reused across credentials.
+type MinioClientPool struct {
+	cfg       *appconfig.EnvConfig
+	transport http.RoundTripper
+	maxSize   int
+	idleTTL   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	evictions   atomic.Int64
+	expirations atomic.Int64
+}
+
+type pooledMinioClient struct {
+	key      string
+	client   *MinioClient
+	lastUsed time.Time
+}
+
+// MinioClientPoolStats is a snapshot of the pool counters
+type MinioClientPoolStats struct {
+	Size        int
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
+}
+
+// NewMinioClientPool creates an empty pool holding at most maxSize clients
+func NewMinioClientPool(cfg *appconfig.EnvConfig, maxSize int, idleTTL time.Duration) (*MinioClientPool, error) {
+	transport, err := minio.DefaultTransport(cfg.Minio.UseSSL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO transport: %w", err)
+	}
+
+	return &MinioClientPool{
+		cfg:       cfg,
+		transport: transport,
+		maxSize:   maxSize,
+		idleTTL:   idleTTL,
+		entries:   map[string]*list.Element{},
+		lru:       list.New(),
+	}, nil
+}
+
+// Get returns the pooled client for the credentials, creating it on a miss
+func (p *MinioClientPool) Get(accessKey, secretKey string) (*MinioClient, error) {
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("access key and secret key cannot be empty")
+	}
+
+	key := CredentialHash(accessKey, secretKey)
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expireIdle(now)
+
+	if element, ok := p.entries[key]; ok {
+		entry := element.Value.(*pooledMinioClient)
+		entry.lastUsed = now
+		p.lru.MoveToFront(element)
+		p.hits.Add(1)
+		return entry.client, nil
+	}
+	p.misses.Add(1)
+
+	client, err := minio.New(p.cfg.Minio.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:    p.cfg.Minio.UseSSL,
+		Transport: p.transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO client with custom credentials: %w", err)
+	}
+
+	entry := &pooledMinioClient{
+		key:      key,
+		client:   &MinioClient{Client: client, BucketName: p.cfg.Minio.BucketName},
+		lastUsed: now,
+	}
+	p.entries[key] = p.lru.PushFront(entry)
+
+	for p.lru.Len() > p.maxSize {
+		p.remove(p.lru.Back())
+		p.evictions.Add(1)
+	}
+	return entry.client, nil
+}
+
+// expireIdle drops clients unused for longer than the idle TTL, callers hold mu
+func (p *MinioClientPool) expireIdle(now time.Time) {
+	for element := p.lru.Back(); element != nil; element = p.lru.Back() {
+		if now.Sub(element.Value.(*pooledMinioClient).lastUsed) <= p.idleTTL {
+			return
+		}
+		p.remove(element)
+		p.expirations.Add(1)
+	}
+}
+
+func (p *MinioClientPool) remove(element *list.Element) {
+	p.lru.Remove(element)
+	delete(p.entries, element.Value.(*pooledMinioClient).key)
+}
+
+// Stats returns the current pool size and counters
+func (p *MinioClientPool) Stats() MinioClientPoolStats {
+	p.mu.Lock()
+	size := p.lru.Len()
+	p.mu.Unlock()
+
+	return MinioClientPoolStats{
+		Size:        size,
+		Hits:        p.hits.Load(),
+		Misses:      p.misses.Load(),
+		Evictions:   p.evictions.Load(),
+		Expirations: p.expirations.Load(),
+	}
+}
+
+// RegisterMetrics exports the pool counters through OpenTelemetry
+func (p *MinioClientPool) RegisterMetrics(meter metricwrap.Meter) error {
+	size, err := meter.Int64ObservableGauge("cdn.minio_client_pool.size",
+		metricwrap.WithDescription("MinIO clients cached for custom credentials"))
+	if err != nil {
+		return err
+	}
+	hits, err := meter.Int64ObservableCounter("cdn.minio_client_pool.hits")
+	if err != nil {
+		return err
+	}
+	misses, err := meter.Int64ObservableCounter("cdn.minio_client_pool.misses")
+	if err != nil {
+		return err
+	}
+	evictions, err := meter.Int64ObservableCounter("cdn.minio_client_pool.evictions")
+	if err != nil {
+		return err
+	}
+	expirations, err := meter.Int64ObservableCounter("cdn.minio_client_pool.expirations")
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metricwrap.Observer) error {
+		stats := p.Stats()
+		o.ObserveInt64(size, int64(stats.Size))
+		o.ObserveInt64(hits, stats.Hits)
+		o.ObserveInt64(misses, stats.Misses)
+		o.ObserveInt64(evictions, stats.Evictions)
+		o.ObserveInt64(expirations, stats.Expirations)
+		return nil
+	}, size, hits, misses, evictions, expirations)
+	return err
+}
+
+// CredentialHash identifies a credential pair without keeping the secret, for pool and cache keys
+func CredentialHash(accessKey, secretKey string) string {
+	sum := sha256.Sum256([]byte(accessKey + "\x00" + secretKey))
+	return hex.EncodeToString(sum[:])
+}
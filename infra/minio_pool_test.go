@@ -0,0 +1,117 @@
+package infra
+
+import (
+	"testing"
+	"time"
+
+	appconfig "github.com/tnqbao/gau-cdn-service/config"
+)
+
+func newTestMinioClientPool(t *testing.T, maxSize int, idleTTL time.Duration) *MinioClientPool {
+	t.Helper()
+
+	cfg := &appconfig.EnvConfig{}
+	cfg.Minio.Endpoint = "localhost:9000"
+	pool, err := NewMinioClientPool(cfg, maxSize, idleTTL)
+	if err != nil {
+		t.Fatalf("NewMinioClientPool: %v", err)
+	}
+	return pool
+}
+
+func getClient(t *testing.T, pool *MinioClientPool, accessKey string) *MinioClient {
+	t.Helper()
+
+	client, err := pool.Get(accessKey, accessKey+"-secret")
+	if err != nil {
+		t.Fatalf("Get(%q): %v", accessKey, err)
+	}
+	return client
+}
+
+func TestMinioClientPoolReusesClients(t *testing.T) {
+	pool := newTestMinioClientPool(t, 4, time.Hour)
+
+	first := getClient(t, pool, "alice")
+	if again := getClient(t, pool, "alice"); again != first {
+		t.Error("same credentials got a new client")
+	}
+	if other := getClient(t, pool, "bob"); other == first {
+		t.Error("other credentials share a client")
+	}
+	if client, err := pool.Get("alice", "wrong-secret"); err != nil || client == first {
+		t.Errorf("same access key with another secret = %p, %v, want a separate client", client, err)
+	}
+
+	want := MinioClientPoolStats{Size: 3, Hits: 1, Misses: 3}
+	if stats := pool.Stats(); stats != want {
+		t.Errorf("Stats = %+v, want %+v", stats, want)
+	}
+}
+
+func TestMinioClientPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	pool := newTestMinioClientPool(t, 2, time.Hour)
+
+	alice := getClient(t, pool, "alice")
+	getClient(t, pool, "bob")
+	// alice becomes the most recently used, bob is evicted by carol
+	getClient(t, pool, "alice")
+	getClient(t, pool, "carol")
+
+	if client := getClient(t, pool, "alice"); client != alice {
+		t.Error("recently used client was evicted")
+	}
+	if _, ok := pool.entries[CredentialHash("bob", "bob-secret")]; ok {
+		t.Error("least recently used client kept over the pool size")
+	}
+
+	stats := pool.Stats()
+	if stats.Size != 2 || stats.Evictions != 1 {
+		t.Errorf("Stats = %+v, want size 2 and 1 eviction", stats)
+	}
+}
+
+func TestMinioClientPoolExpiresIdleClients(t *testing.T) {
+	pool := newTestMinioClientPool(t, 4, time.Minute)
+
+	idle := getClient(t, pool, "alice")
+	getClient(t, pool, "bob")
+	pool.entries[CredentialHash("alice", "alice-secret")].Value.(*pooledMinioClient).lastUsed = time.Now().Add(-2 * time.Minute)
+
+	if client := getClient(t, pool, "alice"); client == idle {
+		t.Error("idle client reused after its TTL")
+	}
+
+	stats := pool.Stats()
+	if stats.Size != 2 || stats.Expirations != 1 {
+		t.Errorf("Stats = %+v, want size 2 and 1 expiration", stats)
+	}
+}
+
+func TestMinioClientPoolRejectsEmptyCredentials(t *testing.T) {
+	pool := newTestMinioClientPool(t, 4, time.Hour)
+
+	for _, creds := range [][2]string{{"", "secret"}, {"alice", ""}, {"", ""}} {
+		if _, err := pool.Get(creds[0], creds[1]); err == nil {
+			t.Errorf("Get(%q, %q) succeeded, want error", creds[0], creds[1])
+		}
+	}
+	if size := pool.Stats().Size; size != 0 {
+		t.Errorf("size = %d, want 0", size)
+	}
+}
+
+func TestCredentialHash(t *testing.T) {
+	hash := CredentialHash("alice", "secret")
+
+	if len(hash) != 64 {
+		t.Errorf("hash length = %d, want 64 hex characters", len(hash))
+	}
+	if CredentialHash("alice", "secret") != hash {
+		t.Error("hash is not stable")
+	}
+	// The separator keeps the boundary between access key and secret
+	if CredentialHash("alices", "ecret") == hash {
+		t.Error("different credential pairs share a hash")
+	}
+}
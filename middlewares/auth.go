@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// AuthClaimsKey is the gin context key holding the jwt.MapClaims of an authenticated request
+const AuthClaimsKey = "auth_claims"
+
+// AuthMiddleware enforces the bucket's AUTH_POLICIES entry before any object is read.
+// Requests with a verified signed URL are already authorized and skip the token check.
+func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket := c.Param("bucket")
+		policy := cfg.EnvConfig.AuthPolicy(bucket)
+		if policy.Mode == config.AuthModePublic || utils.SignedURLFromContext(c) != nil {
+			c.Next()
+			return
+		}
+
+		tokenString := bearerToken(c, cfg.EnvConfig.Auth.CookieName)
+		if tokenString == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="cdn"`)
+			utils.JSON401(c, "authentication required")
+			c.Abort()
+			return
+		}
+
+		claims, err := parseToken(cfg, tokenString)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="cdn", error="invalid_token"`)
+			utils.JSON401(c, "invalid token")
+			c.Abort()
+			return
+		}
+
+		if policy.Mode == config.AuthModeClaims {
+			key := strings.TrimPrefix(c.Param("path"), "/")
+			if !config.AccessAllowed(claimEntries(claims[policy.Claim]), bucket, key) {
+				utils.JSON403(c, "access to this object is not granted")
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set(AuthClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// bearerToken reads the token from the Authorization header, falling back to the auth cookie
+func bearerToken(c *gin.Context, cookieName string) string {
+	if scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if token, err := c.Cookie(cookieName); err == nil {
+		return token
+	}
+	return ""
+}
+
+// parseToken verifies the token signature, expiry and the configured issuer and audience
+func parseToken(cfg *config.Config, tokenString string) (jwt.MapClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if cfg.EnvConfig.Auth.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.EnvConfig.Auth.Issuer))
+	}
+	if cfg.EnvConfig.Auth.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.EnvConfig.Auth.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := cfg.EnvConfig.Auth.Keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+
+		// The key type must match the algorithm, otherwise a public key could be used as an HMAC secret
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			if _, ok := key.(*rsa.PublicKey); ok {
+				return key, nil
+			}
+		case *jwt.SigningMethodECDSA:
+			if _, ok := key.(*ecdsa.PublicKey); ok {
+				return key, nil
+			}
+		case *jwt.SigningMethodHMAC:
+			if _, ok := key.([]byte); ok {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("key %q does not match algorithm %s", kid, token.Method.Alg())
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// claimEntries reads a claim holding either a JSON array or a space separated string
+func claimEntries(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		entries := make([]string, 0, len(value))
+		for _, item := range value {
+			if entry, ok := item.(string); ok {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,257 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+type authTestKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	hmac []byte
+}
+
+func newAuthTestConfig(t *testing.T) (*config.Config, authTestKeys) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := authTestKeys{rsa: rsaKey, ec: ecKey, hmac: []byte("hmac-secret")}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := `{"keys": [
+		{"kty": "RSA", "kid": "rsa", "n": "` + b64(rsaKey.N.Bytes()) + `", "e": "` + b64(big.NewInt(int64(rsaKey.E)).Bytes()) + `"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "` + b64(ecKey.X.Bytes()) + `", "y": "` + b64(ecKey.Y.Bytes()) + `"},
+		{"kty": "oct", "kid": "hmac", "k": "` + b64(keys.hmac) + `"}
+	]}`
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Auth.Keys = &config.KeySet{}
+	if err := cfg.EnvConfig.Auth.Keys.Load([]byte(jwks)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg.EnvConfig.Auth.CookieName = "access_token"
+	cfg.EnvConfig.Auth.Issuer = "https://auth.example.com"
+	cfg.EnvConfig.Auth.Audience = "cdn"
+	cfg.EnvConfig.Auth.Policies = map[string]config.AuthPolicy{
+		"public":  {Mode: config.AuthModePublic},
+		"members": {Mode: config.AuthModeAuthenticated},
+		"tenants": {Mode: config.AuthModeClaims},
+		"custom":  {Mode: config.AuthModeClaims, Claim: "paths"},
+	}
+	return cfg, keys
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign %s: %v", method.Alg(), err)
+	}
+	return signed
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg, keys := newAuthTestConfig(t)
+
+	r := gin.New()
+	r.GET("/:bucket/*path", func(c *gin.Context) {
+		// Stands in for SignedURLMiddleware
+		if c.Query("signed") == "1" {
+			c.Set(utils.SignedURLKey, &utils.SignedURL{KeyID: "k"})
+		}
+	}, AuthMiddleware(cfg), func(c *gin.Context) {
+		if claims, ok := c.Get(AuthClaimsKey); ok {
+			c.String(http.StatusOK, "sub:"+claims.(jwt.MapClaims)["sub"].(string))
+			return
+		}
+		c.String(http.StatusOK, "anonymous")
+	})
+
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		base := jwt.MapClaims{
+			"sub": "user-1",
+			"iss": "https://auth.example.com",
+			"aud": "cdn",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range extra {
+			if value == nil {
+				delete(base, name)
+				continue
+			}
+			base[name] = value
+		}
+		return base
+	}
+
+	rsaToken := signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(nil))
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER})
+	noneToken := signToken(t, jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, claims(nil))
+
+	tests := []struct {
+		name   string
+		path   string
+		bearer string
+		cookie string
+		status int
+		body   string
+	}{
+		{name: "public bucket without token", path: "/public/a.png", status: http.StatusOK, body: "anonymous"},
+		{name: "bucket without policy is public", path: "/other/a.png", status: http.StatusOK, body: "anonymous"},
+		{name: "missing token", path: "/members/a.png", status: http.StatusUnauthorized},
+		{name: "signed URL skips the token check", path: "/members/a.png?signed=1", status: http.StatusOK, body: "anonymous"},
+
+		{name: "RS256 bearer", path: "/members/a.png", bearer: rsaToken, status: http.StatusOK, body: "sub:user-1"},
+		{name: "ES256 bearer", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodES256, "ec", keys.ec, claims(nil)), status: http.StatusOK, body: "sub:user-1"},
+		{name: "HS256 bearer", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodHS256, "hmac", keys.hmac, claims(nil)), status: http.StatusOK, body: "sub:user-1"},
+		{name: "token in cookie", path: "/members/a.png", cookie: rsaToken, status: http.StatusOK, body: "sub:user-1"},
+		{name: "bearer wins over cookie", path: "/members/a.png", bearer: "not-a-token", cookie: rsaToken, status: http.StatusUnauthorized},
+
+		{name: "HS256 signed with the RSA public key", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodHS256, "rsa", publicKeyPEM, claims(nil)), status: http.StatusUnauthorized},
+		{name: "HS256 signed with the RSA modulus", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodHS256, "rsa", keys.rsa.N.Bytes(), claims(nil)), status: http.StatusUnauthorized},
+		{name: "RS256 token naming the HMAC key", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "hmac", keys.rsa, claims(nil)), status: http.StatusUnauthorized},
+		{name: "ES256 token naming the RSA key", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodES256, "rsa", keys.ec, claims(nil)), status: http.StatusUnauthorized},
+		{name: "alg none", path: "/members/a.png", bearer: noneToken, status: http.StatusUnauthorized},
+		{name: "unknown kid", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "retired", keys.rsa, claims(nil)), status: http.StatusUnauthorized},
+		{name: "signed by another key", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodHS256, "hmac", []byte("other-secret"), claims(nil)), status: http.StatusUnauthorized},
+
+		{name: "expired", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), status: http.StatusUnauthorized},
+		{name: "expired within leeway", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"exp": time.Now().Add(-10 * time.Second).Unix()})), status: http.StatusOK, body: "sub:user-1"},
+		{name: "missing expiry", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"exp": nil})), status: http.StatusUnauthorized},
+		{name: "not valid yet", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})), status: http.StatusUnauthorized},
+		{name: "wrong audience", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"aud": "billing"})), status: http.StatusUnauthorized},
+		{name: "audience in list", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"aud": []string{"billing", "cdn"}})), status: http.StatusOK, body: "sub:user-1"},
+		{name: "wrong issuer", path: "/members/a.png", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"iss": "https://evil.example.com"})), status: http.StatusUnauthorized},
+
+		{name: "claims without access entries", path: "/tenants/acme/a.txt", bearer: rsaToken, status: http.StatusForbidden},
+		{name: "claims array grants prefix", path: "/tenants/acme/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": []string{"tenants/acme"}})), status: http.StatusOK, body: "sub:user-1"},
+		{name: "claims string grants prefix", path: "/tenants/acme/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": "reports tenants/acme"})), status: http.StatusOK, body: "sub:user-1"},
+		{name: "claims prefix doesn't grant siblings", path: "/tenants/acme-evil/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": []string{"tenants/acme"}})), status: http.StatusForbidden},
+		{name: "claims for another bucket", path: "/tenants/acme/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": []string{"reports"}})), status: http.StatusForbidden},
+		{name: "claims wildcard", path: "/tenants/acme/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": "*"})), status: http.StatusOK, body: "sub:user-1"},
+		{name: "custom claim name", path: "/custom/x/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"paths": []string{"custom/x"}})), status: http.StatusOK, body: "sub:user-1"},
+		{name: "default claim ignored when renamed", path: "/custom/x/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": []string{"custom/x"}})), status: http.StatusForbidden},
+		{name: "invalid claim type", path: "/tenants/acme/a.txt", bearer: signToken(t, jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"cdn_access": 42})), status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("GET %s = %d (%s), want %d", tt.path, w.Code, w.Body.String(), tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareKeyRotation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg, keys := newAuthTestConfig(t)
+
+	r := gin.New()
+	r.GET("/:bucket/*path", AuthMiddleware(cfg), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	token := signToken(t, jwt.SigningMethodHS256, "", keys.hmac, jwt.MapClaims{
+		"iss": "https://auth.example.com",
+		"aud": "cdn",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/members/a.png", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Without kid the token only matches when a single key is configured
+	if code := request(); code != http.StatusUnauthorized {
+		t.Errorf("token without kid against a JWKS = %d, want 401", code)
+	}
+	if err := cfg.EnvConfig.Auth.Keys.Load(keys.hmac); err != nil {
+		t.Fatal(err)
+	}
+	if code := request(); code != http.StatusOK {
+		t.Errorf("token without kid after reload to a raw secret = %d, want 200", code)
+	}
+	if err := cfg.EnvConfig.Auth.Keys.Load([]byte("rotated-secret")); err != nil {
+		t.Fatal(err)
+	}
+	if code := request(); code != http.StatusUnauthorized {
+		t.Errorf("token signed with the retired secret = %d, want 401", code)
+	}
+}
+
+func TestClaimEntries(t *testing.T) {
+	tests := []struct {
+		claim interface{}
+		want  []string
+	}{
+		{nil, nil},
+		{"", []string{}},
+		{"a b/c  d", []string{"a", "b/c", "d"}},
+		{[]interface{}{"a", 1, "b/c"}, []string{"a", "b/c"}},
+		{42.0, nil},
+	}
+
+	for _, tt := range tests {
+		got := claimEntries(tt.claim)
+		if len(got) != len(tt.want) {
+			t.Errorf("claimEntries(%v) = %q, want %q", tt.claim, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("claimEntries(%v) = %q, want %q", tt.claim, got, tt.want)
+				break
+			}
+		}
+	}
+}
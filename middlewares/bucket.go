@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// PublicBucketKey is the gin context key holding the bucket name as it appeared in the URL
+const PublicBucketKey = "public_bucket"
+
+// BucketMiddleware resolves bucket aliases and rejects buckets that are not exposed, before any MinIO call.
+// Handlers see the storage bucket in the "bucket" param.
+func BucketMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("bucket")
+		if name == "" {
+			c.Next()
+			return
+		}
+
+		bucket, ok := cfg.EnvConfig.ResolveBucket(name)
+		if !ok {
+			utils.JSON404(c, "bucket not found")
+			c.Abort()
+			return
+		}
+
+		c.Set(PublicBucketKey, name)
+		setParam(c, "bucket", bucket)
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+func TestBucketMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Buckets.Exposed = []string{"media"}
+	cfg.EnvConfig.Buckets.Aliases = map[string]string{"img": "prod-media-2024"}
+	cfg.EnvConfig.VirtualHosts = map[string]config.VirtualHost{
+		"assets.example.com": {Bucket: "img"},
+	}
+
+	r := gin.New()
+	cdn := r.Group("/", VirtualHostMiddleware(cfg), BucketMiddleware(cfg))
+	for _, pattern := range []string{"/", "/:bucket", "/:bucket/*path"} {
+		cdn.GET(pattern, func(c *gin.Context) {
+			c.String(http.StatusOK, c.Param("bucket")+" "+c.GetString(PublicBucketKey)+" "+c.Param("path"))
+		})
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{name: "exposed bucket", path: "/media/a.png", status: http.StatusOK, body: "media media /a.png"},
+		{name: "alias", path: "/img/a.png", status: http.StatusOK, body: "prod-media-2024 img /a.png"},
+		{name: "alias target by its own name", path: "/prod-media-2024/a.png", status: http.StatusNotFound},
+		{name: "bucket not exposed", path: "/internal/secrets.json", status: http.StatusNotFound},
+		{name: "listing of a hidden bucket", path: "/internal/?list", status: http.StatusNotFound},
+		{name: "virtual host mapped to an alias", host: "assets.example.com", path: "/a.png", status: http.StatusOK, body: "prod-media-2024 img /a.png"},
+		{name: "virtual host path naming a hidden bucket", host: "assets.example.com", path: "/internal/a.png", status: http.StatusOK, body: "prod-media-2024 img /internal/a.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("GET %s = %d (%s), want %d", tt.path, w.Code, w.Body.String(), tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
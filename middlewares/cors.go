@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// CORSMiddleware applies the CORS policy of the requested bucket and answers preflight requests.
+// Responses of CORS-enabled buckets always carry Vary: Origin, so shared caches keep one copy per origin
+// while the Redis cache, which stores bodies only, stays shared.
+func CORSMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cors := cfg.EnvConfig.CORSPolicy(c.Param("bucket"))
+		if cors == nil {
+			c.Next()
+			return
+		}
+
+		utils.AddVary(c, "Origin")
+		origin := c.GetHeader("Origin")
+		requestMethod := c.GetHeader("Access-Control-Request-Method")
+		preflight := c.Request.Method == http.MethodOptions && requestMethod != ""
+
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		if !cors.AllowsOrigin(origin) {
+			if preflight {
+				utils.JSON403(c, "origin not allowed")
+				c.Abort()
+				return
+			}
+			// Actual requests are served without CORS headers, the browser blocks the read
+			c.Next()
+			return
+		}
+
+		if cors.AllowsAnyOrigin() && !cors.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cors.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(cors.ExposedHeaders) > 0 {
+				c.Header("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+			}
+			c.Next()
+			return
+		}
+
+		utils.AddVary(c, "Access-Control-Request-Method")
+		utils.AddVary(c, "Access-Control-Request-Headers")
+
+		if !cors.AllowsMethod(requestMethod) {
+			utils.JSON403(c, "method not allowed")
+			c.Abort()
+			return
+		}
+
+		methods := cors.AllowedMethods
+		if len(methods) == 0 {
+			methods = []string{http.MethodGet, http.MethodHead}
+		}
+		c.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+		if requestHeaders := c.GetHeader("Access-Control-Request-Headers"); requestHeaders != "" {
+			if len(cors.AllowedHeaders) == 1 && cors.AllowedHeaders[0] == "*" {
+				c.Header("Access-Control-Allow-Headers", requestHeaders)
+			} else if len(cors.AllowedHeaders) > 0 {
+				c.Header("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+			}
+		}
+		if cors.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
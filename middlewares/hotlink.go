@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// PlaceholderHandler serves the placeholder object key from bucket in place of a hotlinked object
+type PlaceholderHandler func(c *gin.Context, bucket, key string)
+
+// HotlinkMiddleware checks Referer (or Origin) against the bucket's allowed domains.
+// Signed URLs bypass the check, they are already scoped by whoever issued them.
+func HotlinkMiddleware(cfg *config.Config, placeholder PlaceholderHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket := c.Param("bucket")
+		policy := cfg.EnvConfig.HotlinkPolicy(bucket)
+		if policy == nil {
+			c.Next()
+			return
+		}
+
+		// Whether the object or the hotlink response is sent depends on these headers, shared caches
+		// must not hand one embedder's response to another
+		utils.AddVary(c, "Referer")
+		utils.AddVary(c, "Origin")
+
+		if utils.SignedURLFromContext(c) != nil || refererAllowed(c, policy) {
+			c.Next()
+			return
+		}
+
+		switch policy.Action {
+		case config.HotlinkActionRedirect:
+			if policy.RedirectURL != "" {
+				c.Header("Cache-Control", "no-store")
+				c.Redirect(http.StatusFound, policy.RedirectURL)
+				c.Abort()
+				return
+			}
+		case config.HotlinkActionPlaceholder:
+			if policy.Placeholder != "" {
+				placeholderBucket := policy.PlaceholderBucket
+				if placeholderBucket == "" {
+					placeholderBucket = bucket
+				}
+				placeholder(c, placeholderBucket, policy.Placeholder)
+				c.Abort()
+				return
+			}
+		}
+
+		utils.JSON403(c, "hotlinking is not allowed")
+		c.Abort()
+	}
+}
+
+// refererAllowed reports whether the embedding site may load objects under policy
+func refererAllowed(c *gin.Context, policy *config.HotlinkPolicy) bool {
+	source := c.GetHeader("Referer")
+	if source == "" {
+		source = c.GetHeader("Origin")
+	}
+	if source == "" || source == "null" {
+		return policy.AllowEmpty
+	}
+
+	referer, err := url.Parse(source)
+	if err != nil || referer.Host == "" {
+		return false
+	}
+
+	// Pages served by the CDN itself (static sites, listings) may embed their own objects
+	if config.NormalizeHost(referer.Host) == config.NormalizeHost(c.Request.Host) {
+		return true
+	}
+	return policy.AllowsDomain(referer.Host)
+}
@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+func TestHotlinkMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Hotlink = map[string]config.HotlinkPolicy{
+		"media":   {AllowedDomains: []string{"*.example.com", "partner.net"}},
+		"open":    {AllowedDomains: []string{"example.com"}, AllowEmpty: true},
+		"images":  {AllowedDomains: []string{"example.com"}, Action: config.HotlinkActionPlaceholder, Placeholder: "hotlink.png"},
+		"shared":  {AllowedDomains: []string{"example.com"}, Action: config.HotlinkActionPlaceholder, Placeholder: "hotlink.png", PlaceholderBucket: "assets"},
+		"video":   {AllowedDomains: []string{"example.com"}, Action: config.HotlinkActionRedirect, RedirectURL: "https://example.com/watch"},
+		"broken":  {AllowedDomains: []string{"example.com"}, Action: config.HotlinkActionRedirect},
+		"nothing": {AllowedDomains: []string{"example.com"}, Action: config.HotlinkActionPlaceholder},
+	}
+
+	placeholder := func(c *gin.Context, bucket, key string) {
+		c.String(http.StatusOK, "placeholder:"+bucket+"/"+key)
+	}
+
+	r := gin.New()
+	r.GET("/:bucket/*path", func(c *gin.Context) {
+		if c.Query("sig") != "" {
+			c.Set(utils.SignedURLKey, &utils.SignedURL{KeyID: "2025"})
+		}
+		c.Next()
+	}, HotlinkMiddleware(cfg, placeholder), func(c *gin.Context) {
+		c.String(http.StatusOK, "object")
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		referer  string
+		origin   string
+		status   int
+		body     string
+		location string
+	}{
+		{name: "exact domain", path: "/media/a.png", referer: "https://partner.net/page", status: http.StatusOK, body: "object"},
+		{name: "wildcard subdomain", path: "/media/a.png", referer: "https://cdn.example.com/page", status: http.StatusOK, body: "object"},
+		{name: "wildcard nested subdomain", path: "/media/a.png", referer: "https://a.b.example.com/", status: http.StatusOK, body: "object"},
+		{name: "wildcard apex", path: "/media/a.png", referer: "https://example.com/", status: http.StatusOK, body: "object"},
+		{name: "wildcard with port and case", path: "/media/a.png", referer: "https://WWW.Example.com:8443/", status: http.StatusOK, body: "object"},
+		{name: "wildcard suffix without dot", path: "/media/a.png", referer: "https://badexample.com/", status: http.StatusForbidden},
+		{name: "allowed domain as subdomain of another", path: "/media/a.png", referer: "https://example.com.evil.net/", status: http.StatusForbidden},
+		{name: "subdomain of exact domain", path: "/media/a.png", referer: "https://www.partner.net/", status: http.StatusForbidden},
+		{name: "origin when referer is missing", path: "/media/a.png", origin: "https://cdn.example.com", status: http.StatusOK, body: "object"},
+		{name: "CDN's own pages", path: "/media/a.png", referer: "http://example.org/media/index.html", status: http.StatusOK, body: "object"},
+		{name: "malformed referer", path: "/media/a.png", referer: "not a url", status: http.StatusForbidden},
+		{name: "signed URL bypasses the check", path: "/media/a.png?sig=x", referer: "https://other.net/", status: http.StatusOK, body: "object"},
+
+		{name: "empty referer denied by default", path: "/media/a.png", status: http.StatusForbidden},
+		{name: "null origin denied by default", path: "/media/a.png", origin: "null", status: http.StatusForbidden},
+		{name: "empty referer with allow_empty", path: "/open/a.png", status: http.StatusOK, body: "object"},
+		{name: "null origin with allow_empty", path: "/open/a.png", origin: "null", status: http.StatusOK, body: "object"},
+		{name: "allow_empty still checks a present referer", path: "/open/a.png", referer: "https://other.net/", status: http.StatusForbidden},
+
+		{name: "forbid", path: "/media/a.png", referer: "https://other.net/", status: http.StatusForbidden},
+		{name: "placeholder from the requested bucket", path: "/images/a.png", referer: "https://other.net/", status: http.StatusOK, body: "placeholder:images/hotlink.png"},
+		{name: "placeholder from another bucket", path: "/shared/a.png", referer: "https://other.net/", status: http.StatusOK, body: "placeholder:assets/hotlink.png"},
+		{name: "placeholder not used for allowed referer", path: "/images/a.png", referer: "https://example.com/", status: http.StatusOK, body: "object"},
+		{name: "placeholder without key falls back to forbid", path: "/nothing/a.png", referer: "https://other.net/", status: http.StatusForbidden},
+		{name: "redirect", path: "/video/a.mp4", referer: "https://other.net/", status: http.StatusFound, location: "https://example.com/watch"},
+		{name: "redirect without URL falls back to forbid", path: "/broken/a.mp4", referer: "https://other.net/", status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = "example.org"
+			if tt.referer != "" {
+				req.Header.Set("Referer", tt.referer)
+			}
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body.String(), tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if tt.location != "" {
+				if got := w.Header().Get("Location"); got != tt.location {
+					t.Errorf("Location = %q, want %q", got, tt.location)
+				}
+				if got := w.Header().Get("Cache-Control"); got != "no-store" {
+					t.Errorf("Cache-Control = %q, want no-store", got)
+				}
+			}
+		})
+	}
+}
+
+func TestHotlinkMiddlewareVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Hotlink = map[string]config.HotlinkPolicy{
+		"media": {AllowedDomains: []string{"example.com"}},
+	}
+
+	r := gin.New()
+	r.GET("/:bucket/*path", HotlinkMiddleware(cfg, nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		referer  string
+		status   int
+		wantVary bool
+	}{
+		{"allowed referer", "/media/a.png", "https://example.com/page", http.StatusOK, true},
+		{"hotlinked", "/media/a.png", "https://other.net/page", http.StatusForbidden, true},
+		{"bucket without policy", "/public/a.png", "https://other.net/page", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Referer", tt.referer)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			vary := w.Header().Values("Vary")
+			hasReferer := false
+			for _, v := range vary {
+				if v == "Referer" {
+					hasReferer = true
+				}
+			}
+			if hasReferer != tt.wantVary {
+				t.Errorf("Vary = %v, want Referer present: %t", vary, tt.wantVary)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// IPFilterMiddleware rejects clients outside the global and per-bucket CIDR lists.
+// The client IP honours forwarding headers only from TRUSTED_PROXIES.
+func IPFilterMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr, err := netip.ParseAddr(c.ClientIP())
+		if err != nil || !cfg.EnvConfig.Network.IPFilter.Allowed(c.Param("bucket"), addr) {
+			utils.JSON403(c, "access denied for this address")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
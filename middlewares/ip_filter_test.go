@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+func TestIPFilterMiddlewareClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.Network.TrustedProxies = []string{"10.0.0.0/8"}
+	cfg.EnvConfig.Network.IPFilter = &config.IPFilter{}
+	if err := cfg.EnvConfig.Network.IPFilter.Load([]byte(`{
+		"deny": ["203.0.113.0/24"],
+		"buckets": {"internal": {"allow": ["192.0.2.0/24"]}}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same trust setup as routes.SetupRouter
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.EnvConfig.Network.TrustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/:bucket/*path", IPFilterMiddleware(cfg), func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		bucket       string
+		status       int
+		wantClientIP string
+	}{
+		{name: "direct client", remoteAddr: "198.51.100.1", bucket: "media", status: http.StatusOK, wantClientIP: "198.51.100.1"},
+		{name: "direct denied client", remoteAddr: "203.0.113.5", bucket: "media", status: http.StatusForbidden},
+		{name: "direct client outside bucket allow list", remoteAddr: "198.51.100.1", bucket: "internal", status: http.StatusForbidden},
+		{name: "direct client inside bucket allow list", remoteAddr: "192.0.2.7", bucket: "internal", status: http.StatusOK, wantClientIP: "192.0.2.7"},
+
+		{name: "untrusted peer spoofs an allowed address", remoteAddr: "198.51.100.1", forwardedFor: "192.0.2.7", bucket: "internal", status: http.StatusForbidden},
+		{name: "denied peer hides behind a forwarded address", remoteAddr: "203.0.113.5", forwardedFor: "198.51.100.1", bucket: "media", status: http.StatusForbidden},
+		{name: "untrusted peer spoofs X-Real-IP", remoteAddr: "198.51.100.1", realIP: "192.0.2.7", bucket: "internal", status: http.StatusForbidden},
+
+		{name: "trusted proxy forwards an allowed client", remoteAddr: "10.0.0.2", forwardedFor: "192.0.2.7", bucket: "internal", status: http.StatusOK, wantClientIP: "192.0.2.7"},
+		{name: "trusted proxy forwards a denied client", remoteAddr: "10.0.0.2", forwardedFor: "203.0.113.5", bucket: "media", status: http.StatusForbidden},
+		{name: "proxy chain skips trusted hops", remoteAddr: "10.0.0.2", forwardedFor: "192.0.2.7, 10.0.0.3", bucket: "internal", status: http.StatusOK, wantClientIP: "192.0.2.7"},
+		{name: "client-supplied entry before an untrusted hop is ignored", remoteAddr: "10.0.0.2", forwardedFor: "192.0.2.7, 198.51.100.1", bucket: "internal", status: http.StatusForbidden},
+		{name: "trusted proxy without forwarding header", remoteAddr: "10.0.0.2", bucket: "media", status: http.StatusOK, wantClientIP: "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.bucket+"/a.png", nil)
+			req.RemoteAddr = tt.remoteAddr + ":40000"
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.wantClientIP != "" && w.Body.String() != tt.wantClientIP {
+				t.Errorf("client IP = %s, want %s", w.Body.String(), tt.wantClientIP)
+			}
+		})
+	}
+}
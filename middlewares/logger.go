@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// redactedParams are query parameters whose values never reach the access log
+var redactedParams = []string{"access_key", "secret_key", utils.SignatureParam}
+
+// Logger is gin's access log with credentials and signatures masked in the logged URL
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Formatter: func(param gin.LogFormatterParams) string {
+			if param.Latency > time.Minute {
+				param.Latency = param.Latency.Truncate(time.Second)
+			}
+			return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				param.StatusCode,
+				param.Latency,
+				param.ClientIP,
+				param.Method,
+				redactURL(param.Path),
+				param.ErrorMessage,
+			)
+		},
+	})
+}
+
+// redactURL masks sensitive query parameter values in a request URI
+func redactURL(requestURI string) string {
+	path, rawQuery, ok := strings.Cut(requestURI, "?")
+	if !ok {
+		return requestURI
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Don't risk logging a credential we failed to parse
+		return path + "?REDACTED"
+	}
+
+	redacted := false
+	for _, name := range redactedParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return requestURI
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,134 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/repository"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// RateLimitMiddleware enforces RATE_LIMITS with GCRA counters in Redis, shared across replicas.
+// Responses carry RateLimit-* headers for the most constrained limiter. Redis errors fail open.
+func RateLimitMiddleware(cfg *config.Config, repo *repository.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := cfg.EnvConfig.RateLimits
+		if len(rules) == 0 {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		bucket := c.Param("bucket")
+
+		var tightest *repository.RateLimitResult
+		var denied *repository.RateLimitResult
+		var byteLimits []rateLimitCharge
+
+		track := func(result *repository.RateLimitResult) {
+			if tightest == nil || remainingRatio(result) < remainingRatio(tightest) {
+				tightest = result
+			}
+			if !result.Allowed && (denied == nil || result.RetryAfter > denied.RetryAfter) {
+				denied = result
+			}
+		}
+
+		for i := range rules {
+			rule := &rules[i]
+			if !rule.Matches(bucket) {
+				continue
+			}
+			identity := rateLimitIdentity(c, rule.Key)
+			if identity == "" {
+				continue
+			}
+			key := "cdn:ratelimit:" + rule.Name + ":" + identity
+
+			if rule.RequestsPerSecond > 0 {
+				result, err := repo.RateLimit(ctx, key+":req", rule.RequestsPerSecond, rule.Burst, 1, false)
+				if err != nil {
+					log.Printf("Rate limit check failed, allowing request: rule=%s, error=%v", rule.Name, err)
+				} else {
+					track(result)
+				}
+			}
+
+			if rule.BytesPerSecond > 0 {
+				result, err := repo.RateLimit(ctx, key+":bytes", rule.BytesPerSecond, rule.BytesBurst, 0, false)
+				if err != nil {
+					log.Printf("Rate limit check failed, allowing request: rule=%s, error=%v", rule.Name, err)
+				} else {
+					track(result)
+					byteLimits = append(byteLimits, rateLimitCharge{key: key + ":bytes", rule: rule})
+				}
+			}
+		}
+
+		if tightest != nil {
+			setRateLimitHeaders(c, tightest)
+		}
+		if denied != nil {
+			setRateLimitHeaders(c, denied)
+			c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(denied.RetryAfter.Seconds())), 10))
+			utils.JSON429(c, "rate limit exceeded")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		// Bill the bytes actually sent; the client waits out any debt on its next request
+		if written := c.Writer.Size(); written > 0 {
+			for _, charge := range byteLimits {
+				if _, err := repo.RateLimit(context.Background(), charge.key, charge.rule.BytesPerSecond, charge.rule.BytesBurst, int64(written), true); err != nil {
+					log.Printf("Failed to charge rate limit bytes: rule=%s, error=%v", charge.rule.Name, err)
+				}
+			}
+		}
+	}
+}
+
+type rateLimitCharge struct {
+	key  string
+	rule *config.RateLimitRule
+}
+
+// rateLimitIdentity returns what the request is counted by, or "" when the rule does not apply
+func rateLimitIdentity(c *gin.Context, key string) string {
+	switch key {
+	case config.RateLimitKeyBucket:
+		return c.Param("bucket")
+	case config.RateLimitKeyToken:
+		if claims, ok := c.Get(AuthClaimsKey); ok {
+			if subject, err := claims.(jwt.MapClaims).GetSubject(); err == nil && subject != "" {
+				return "sub:" + subject
+			}
+		}
+		if signature := c.Query(utils.SignatureParam); signature != "" && utils.SignedURLFromContext(c) != nil {
+			sum := sha256.Sum256([]byte(signature))
+			return "sig:" + hex.EncodeToString(sum[:8])
+		}
+		return ""
+	default:
+		return "ip:" + c.ClientIP()
+	}
+}
+
+func remainingRatio(result *repository.RateLimitResult) float64 {
+	return float64(result.Remaining) / float64(result.Limit)
+}
+
+// setRateLimitHeaders sends the limiter state as RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+func setRateLimitHeaders(c *gin.Context, result *repository.RateLimitResult) {
+	c.Header("RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	c.Header("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	c.Header("RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(result.ResetAfter.Seconds())), 10))
+}
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+// RewriteMiddleware applies the redirect and rewrite rules to the resolved "/bucket/key" path.
+// Redirects end the request, rewrites swap the "bucket" and "path" params and the query string
+// so the handlers serve the target object.
+func RewriteMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bucket := c.Param("bucket")
+		if bucket == "" {
+			c.Next()
+			return
+		}
+
+		result := cfg.EnvConfig.Rewrites.Apply("/"+bucket+c.Param("path"), c.Request.URL.RawQuery)
+		if result == nil {
+			c.Next()
+			return
+		}
+
+		if result.IsRedirect() {
+			c.Header("X-Rewrite-Rule", result.RuleName)
+			c.Redirect(result.Status, result.Location)
+			c.Abort()
+			return
+		}
+
+		targetBucket, targetKey, _ := strings.Cut(strings.TrimPrefix(result.Path, "/"), "/")
+		setParam(c, "bucket", targetBucket)
+		setParam(c, "path", "/"+targetKey)
+		c.Request.URL.RawQuery = result.RawQuery
+		c.Header("X-Rewrite-Rule", result.RuleName)
+		c.Next()
+	}
+}
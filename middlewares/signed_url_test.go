@@ -0,0 +1,213 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// signedURL signs path with the given key and parameters, like an issuing backend would
+func signedURL(path, kid, secret string, params url.Values) string {
+	query := url.Values{}
+	for name, values := range params {
+		query[name] = values
+	}
+	query.Set(utils.KeyIDParam, kid)
+	query.Set(utils.SignatureParam, utils.SignURL(secret, path, query))
+	return path + "?" + query.Encode()
+}
+
+func TestSignedURLMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	// Key rotation: "2025" is the new key, "2024" is still accepted until it is removed
+	cfg.EnvConfig.SignedURL.Keys = map[string]string{"2024": "old-secret", "2025": "new-secret"}
+	cfg.EnvConfig.SignedURL.Buckets = []string{"private"}
+
+	r := gin.New()
+	r.GET("/:bucket/*path", SignedURLMiddleware(cfg), func(c *gin.Context) {
+		if signed := utils.SignedURLFromContext(c); signed != nil {
+			c.String(http.StatusOK, "signed:"+signed.KeyID+":"+signed.ByteRange+":"+signed.Tier)
+			return
+		}
+		c.String(http.StatusOK, "unsigned")
+	})
+
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	valid := url.Values{utils.ExpiresParam: {future}}
+
+	tamper := func(target, name, value string) string {
+		u, _ := url.Parse(target)
+		query := u.Query()
+		query.Set(name, value)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		clientIP string
+		status   int
+		body     string
+	}{
+		{
+			name:   "current key",
+			target: signedURL("/private/a.mp4", "2025", "new-secret", valid),
+			status: http.StatusOK, body: "signed:2025::",
+		},
+		{
+			name:   "previous key during rotation",
+			target: signedURL("/private/a.mp4", "2024", "old-secret", valid),
+			status: http.StatusOK, body: "signed:2024::",
+		},
+		{
+			name:   "retired key",
+			target: signedURL("/private/a.mp4", "2023", "older-secret", valid),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "secret of another kid",
+			target: signedURL("/private/a.mp4", "2025", "old-secret", valid),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "constraints are passed on",
+			target: signedURL("/private/a.mp4", "2025", "new-secret", url.Values{utils.ExpiresParam: {future}, utils.ByteRangeParam: {"0-99"}, utils.TierParam: {"premium"}}),
+			status: http.StatusOK, body: "signed:2025:0-99:premium",
+		},
+		{
+			name:   "tampered range",
+			target: tamper(signedURL("/private/a.mp4", "2025", "new-secret", url.Values{utils.ExpiresParam: {future}, utils.ByteRangeParam: {"0-99"}}), utils.ByteRangeParam, "0-"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "tampered expiry",
+			target: tamper(signedURL("/private/a.mp4", "2025", "new-secret", valid), utils.ExpiresParam, "9999999999"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "added tier",
+			target: tamper(signedURL("/private/a.mp4", "2025", "new-secret", valid), utils.TierParam, "premium"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "signed listing",
+			target: signedURL("/private/", "2025", "new-secret", url.Values{utils.ExpiresParam: {future}, "list": {""}, "prefix": {"docs/"}}),
+			status: http.StatusOK, body: "signed:2025::",
+		},
+		{
+			name:   "listing added to a signed file URL",
+			target: tamper(signedURL("/private/", "2025", "new-secret", valid), "list", ""),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "listing prefix changed",
+			target: tamper(signedURL("/private/", "2025", "new-secret", url.Values{utils.ExpiresParam: {future}, "list": {""}, "prefix": {"docs/"}}), "prefix", ""),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "other parameter added",
+			target: tamper(signedURL("/private/a.mp4", "2025", "new-secret", valid), "download", "a.mp4"),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "signature for another path",
+			target: "/private/b.mp4?" + mustQuery(signedURL("/private/a.mp4", "2025", "new-secret", valid)),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "expired",
+			target: signedURL("/private/a.mp4", "2025", "new-secret", url.Values{utils.ExpiresParam: {past}}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "missing expiry",
+			target: signedURL("/private/a.mp4", "2025", "new-secret", url.Values{}),
+			status: http.StatusForbidden,
+		},
+		{
+			name:     "client inside signed CIDR",
+			target:   signedURL("/private/a.mp4", "2025", "new-secret", url.Values{utils.ExpiresParam: {future}, utils.ClientIPParam: {"10.1.0.0/16"}}),
+			clientIP: "10.1.2.3",
+			status:   http.StatusOK, body: "signed:2025::",
+		},
+		{
+			name:     "client outside signed IP",
+			target:   signedURL("/private/a.mp4", "2025", "new-secret", url.Values{utils.ExpiresParam: {future}, utils.ClientIPParam: {"10.1.2.3"}}),
+			clientIP: "10.9.9.9",
+			status:   http.StatusForbidden,
+		},
+		{
+			name:   "unsigned request to a signed-only bucket",
+			target: "/private/a.mp4",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "query credentials are not accepted by default",
+			target: "/private/a.mp4?access_key=a&secret_key=b",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "unsigned request to a public bucket",
+			target: "/public/a.png",
+			status: http.StatusOK, body: "unsigned",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.clientIP != "" {
+				req.RemoteAddr = tt.clientIP + ":12345"
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("GET %s = %d (%s), want %d", tt.target, w.Code, w.Body.String(), tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestIPMatches(t *testing.T) {
+	tests := []struct {
+		allowed, client string
+		want            bool
+	}{
+		{"10.0.0.1", "10.0.0.1", true},
+		{"10.0.0.1", "10.0.0.2", false},
+		{"10.0.0.0/8", "10.200.1.1", true},
+		{"10.0.0.0/8", "11.0.0.1", false},
+		{"2001:db8::/32", "2001:db8::1", true},
+		{"10.0.0.1", "not-an-ip", false},
+		{"garbage", "10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := ipMatches(tt.allowed, tt.client); got != tt.want {
+			t.Errorf("ipMatches(%q, %q) = %t, want %t", tt.allowed, tt.client, got, tt.want)
+		}
+	}
+}
+
+func mustQuery(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		panic(err)
+	}
+	return u.RawQuery
+}
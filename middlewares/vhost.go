@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+	"github.com/tnqbao/gau-cdn-service/utils"
+)
+
+// VirtualHostKey is set on the gin context with the matched host when a request is served through VHOST_CONFIG
+const VirtualHostKey = "virtual_host"
+
+// VirtualHostMiddleware routes requests for mapped custom domains to their bucket. The whole URL path
+// becomes the object key, so the "bucket" and "path" params are rewritten for the handlers that follow.
+// Unmapped hosts keep the /:bucket/*path form.
+func VirtualHostMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		vhost := cfg.EnvConfig.VirtualHost(c.Request.Host)
+		if vhost == nil {
+			switch c.FullPath() {
+			case "/":
+				utils.JSON404(c, "missing bucket parameter")
+				c.Abort()
+			case "/:bucket":
+				// Same as gin's trailing slash redirect, which the /:bucket route shadows
+				redirectWithSlash(c)
+			default:
+				c.Next()
+			}
+			return
+		}
+
+		// Clean the path so ".." can't climb out of the mapped prefix
+		requestPath := c.Request.URL.Path
+		key := strings.TrimPrefix(path.Clean("/"+requestPath), "/")
+		if strings.HasSuffix(requestPath, "/") && key != "" {
+			key += "/"
+		}
+		key = vhost.Prefix + key
+
+		setParam(c, "bucket", vhost.Bucket)
+		setParam(c, "path", "/"+key)
+		c.Set(VirtualHostKey, config.NormalizeHost(c.Request.Host))
+		c.Next()
+	}
+}
+
+// setParam overwrites a route param, adding it when the matched route does not declare it
+func setParam(c *gin.Context, name, value string) {
+	for i := range c.Params {
+		if c.Params[i].Key == name {
+			c.Params[i].Value = value
+			return
+		}
+	}
+	c.Params = append(c.Params, gin.Param{Key: name, Value: value})
+}
+
+func redirectWithSlash(c *gin.Context) {
+	target := *c.Request.URL
+	target.Path += "/"
+	target.RawPath = ""
+
+	status := http.StatusMovedPermanently
+	if c.Request.Method != http.MethodGet {
+		status = http.StatusTemporaryRedirect
+	}
+	c.Redirect(status, target.RequestURI())
+	c.Abort()
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+func TestVirtualHostMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{EnvConfig: &config.EnvConfig{}}
+	cfg.EnvConfig.VirtualHosts = map[string]config.VirtualHost{
+		"assets.example.com": {Bucket: "brand-assets"},
+		"*.docs.example.com": {Bucket: "docs", Prefix: "site/"},
+	}
+
+	// Same patterns as the CDN routes
+	r := gin.New()
+	cdn := r.Group("/", VirtualHostMiddleware(cfg))
+	for _, pattern := range []string{"/", "/:bucket", "/:bucket/*path"} {
+		cdn.GET(pattern, func(c *gin.Context) {
+			c.String(http.StatusOK, c.Param("bucket")+" "+c.Param("path")+" "+c.GetString(VirtualHostKey))
+		})
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{name: "root of a mapped host", host: "assets.example.com", path: "/", status: http.StatusOK, body: "brand-assets / assets.example.com"},
+		{name: "object at the root", host: "assets.example.com", path: "/logo.png", status: http.StatusOK, body: "brand-assets /logo.png assets.example.com"},
+		{name: "nested object", host: "assets.example.com:8443", path: "/img/2024/logo.png", status: http.StatusOK, body: "brand-assets /img/2024/logo.png assets.example.com"},
+		{name: "first segment is not a bucket", host: "assets.example.com", path: "/media/a.png", status: http.StatusOK, body: "brand-assets /media/a.png assets.example.com"},
+		{name: "wildcard host with prefix", host: "v2.docs.example.com", path: "/guide/intro.html", status: http.StatusOK, body: "docs /site/guide/intro.html v2.docs.example.com"},
+		{name: "directory keeps its trailing slash", host: "v2.docs.example.com", path: "/guide/", status: http.StatusOK, body: "docs /site/guide/ v2.docs.example.com"},
+		{name: "root under a prefix", host: "v2.docs.example.com", path: "/", status: http.StatusOK, body: "docs /site/ v2.docs.example.com"},
+		{name: "dot segments can't leave the prefix", host: "v2.docs.example.com", path: "/guide/../../../secret.txt", status: http.StatusOK, body: "docs /site/secret.txt v2.docs.example.com"},
+
+		{name: "unmapped host", host: "cdn.example.com", path: "/media/a.png", status: http.StatusOK, body: "media /a.png "},
+		{name: "unmapped host without bucket", host: "cdn.example.com", path: "/", status: http.StatusNotFound},
+		{name: "unmapped host bucket without slash", host: "cdn.example.com", path: "/media?list", status: http.StatusMovedPermanently, location: "/media/?list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("GET %s%s = %d (%s), want %d", tt.host, tt.path, w.Code, w.Body.String(), tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+			if tt.location != "" && w.Header().Get("Location") != tt.location {
+				t.Errorf("Location = %q, want %q", w.Header().Get("Location"), tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	diskIndexFile     = "index.json"
+	diskObjectsDir    = "objects"
+	diskTempDir       = "tmp"
+	diskFlushInterval = 10 * time.Second
+)
+
+// ErrDiskObjectIncomplete is returned by Commit when the download ended before the whole object was written
+var ErrDiskObjectIncomplete = errors.New("disk cache object incomplete")
+
+// diskCache keeps large objects in a local directory with an LRU over a byte budget.
+// The index is persisted next to the files so a pod restarted on the same volume keeps its cache.
+type diskCache struct {
+	dir      string
+	budget   int64
+	maxEntry int64
+
+	mu       sync.Mutex
+	used     int64
+	reserved int64 // bytes promised to downloads still being written
+	entries  map[string]*list.Element
+	lru      *list.List // front is most recently used
+	writing  map[string]bool
+	dirty    bool
+
+	stats tierStats
+}
+
+type diskEntry struct {
+	Key        string    `json:"key"`
+	File       string    `json:"file"`
+	Size       int64     `json:"size"`
+	ETag       string    `json:"etag"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+// newDiskCache opens or creates the cache in dir. Returns nil without error when dir or budget is not set.
+func newDiskCache(dir string, budget int64) (*diskCache, error) {
+	if dir == "" || budget <= 0 {
+		return nil, nil
+	}
+
+	d := &diskCache{
+		dir:    dir,
+		budget: budget,
+		// Like the memory tier, a single object may take a quarter of the budget
+		maxEntry: budget / 4,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		writing:  map[string]bool{},
+	}
+
+	// Temp files are downloads interrupted by a restart, they are never resumed
+	if err := os.RemoveAll(filepath.Join(dir, diskTempDir)); err != nil {
+		return nil, err
+	}
+	for _, sub := range []string{diskObjectsDir, diskTempDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	go d.flushLoop()
+	return d, nil
+}
+
+// loadIndex restores the LRU from the persisted index. Entries whose file is gone are dropped,
+// files the index doesn't know about are deleted.
+func (d *diskCache) loadIndex() error {
+	var entries []*diskEntry
+	data, err := os.ReadFile(filepath.Join(d.dir, diskIndexFile))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &entries); err != nil {
+			log.Printf("Invalid disk cache index, starting empty: %v", err)
+			entries = nil
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	// Least recently used last, so pushing in order rebuilds the list
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastAccess.After(entries[j].LastAccess)
+	})
+
+	known := map[string]bool{}
+	for _, entry := range entries {
+		if entry == nil || entry.File == "" || d.entries[entry.Key] != nil {
+			continue
+		}
+		info, err := os.Stat(d.objectPath(entry.File))
+		if err != nil || info.Size() != entry.Size {
+			continue
+		}
+		known[entry.File] = true
+		d.entries[entry.Key] = d.lru.PushBack(entry)
+		d.used += entry.Size
+	}
+
+	files, err := os.ReadDir(filepath.Join(d.dir, diskObjectsDir))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !known[file.Name()] {
+			_ = os.Remove(d.objectPath(file.Name()))
+		}
+	}
+
+	// The budget may have shrunk since the last run. Nothing else uses the cache yet, so no locking.
+	d.evict(0)
+	d.dirty = true
+
+	log.Printf("Disk cache loaded: dir=%s, entries=%d, size=%d", d.dir, d.lru.Len(), d.used)
+	return nil
+}
+
+// open returns the cached file when it holds the given version of the object
+func (d *diskCache) open(key, etag string, size int64) (*os.File, bool) {
+	if d == nil {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	element, ok := d.entries[key]
+	if !ok {
+		d.stats.misses.Add(1)
+		return nil, false
+	}
+
+	entry := element.Value.(*diskEntry)
+	if entry.ETag != etag || entry.Size != size {
+		// The object changed in MinIO, the next full download replaces it
+		d.remove(element)
+		d.stats.misses.Add(1)
+		return nil, false
+	}
+
+	// Files removed by eviction stay readable through descriptors that are already open
+	file, err := os.Open(d.objectPath(entry.File))
+	if err != nil {
+		d.remove(element)
+		d.stats.misses.Add(1)
+		return nil, false
+	}
+
+	entry.LastAccess = time.Now()
+	d.lru.MoveToFront(element)
+	d.dirty = true
+	d.stats.hits.Add(1)
+	return file, true
+}
+
+// create starts writing an object. Returns nil when the object doesn't fit, or another
+// request is already downloading it.
+func (d *diskCache) create(key, etag string, size int64) *DiskObjectWriter {
+	if d == nil || size <= 0 || size > d.maxEntry {
+		return nil
+	}
+
+	d.mu.Lock()
+	if d.writing[key] {
+		d.mu.Unlock()
+		return nil
+	}
+	if !d.evict(size) {
+		d.mu.Unlock()
+		return nil
+	}
+	d.writing[key] = true
+	d.reserved += size
+	d.mu.Unlock()
+
+	file, err := os.CreateTemp(filepath.Join(d.dir, diskTempDir), "object-*")
+	if err != nil {
+		log.Printf("Failed to create disk cache file: %v", err)
+		d.release(key, size)
+		return nil
+	}
+
+	return &DiskObjectWriter{cache: d, file: file, key: key, etag: etag, size: size}
+}
+
+// commit moves a completed temp file into place and records it in the index
+func (d *diskCache) commit(w *DiskObjectWriter) error {
+	name := diskFileName(w.key)
+
+	// Renamed under the lock so a concurrent lookup can't remove the new file as a stale version
+	d.mu.Lock()
+	delete(d.writing, w.key)
+	d.reserved -= w.size
+	if err := os.Rename(w.file.Name(), d.objectPath(name)); err != nil {
+		d.mu.Unlock()
+		_ = os.Remove(w.file.Name())
+		return err
+	}
+	if element, ok := d.entries[w.key]; ok {
+		// Same key maps to the same file name, which now holds the new version
+		entry := element.Value.(*diskEntry)
+		d.used -= entry.Size
+		d.lru.Remove(element)
+		delete(d.entries, w.key)
+	}
+	entry := &diskEntry{Key: w.key, File: name, Size: w.size, ETag: w.etag, LastAccess: time.Now()}
+	d.entries[w.key] = d.lru.PushFront(entry)
+	d.used += w.size
+	d.dirty = true
+	d.mu.Unlock()
+
+	// New files are flushed right away, a restart before the next tick would otherwise orphan them
+	return d.flush()
+}
+
+// release gives back the reservation of a download that was not committed
+func (d *diskCache) release(key string, size int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.writing, key)
+	d.reserved -= size
+}
+
+// evict drops least recently used entries until size more bytes fit, callers hold mu
+func (d *diskCache) evict(size int64) bool {
+	for d.used+d.reserved+size > d.budget {
+		back := d.lru.Back()
+		if back == nil {
+			return false
+		}
+		d.remove(back)
+		d.stats.evictions.Add(1)
+	}
+	return true
+}
+
+// remove drops an entry and its file, callers hold mu
+func (d *diskCache) remove(element *list.Element) {
+	entry := element.Value.(*diskEntry)
+	d.lru.Remove(element)
+	delete(d.entries, entry.Key)
+	d.used -= entry.Size
+	d.dirty = true
+	if err := os.Remove(d.objectPath(entry.File)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove disk cache file %s: %v", entry.File, err)
+	}
+}
+
+// size returns the bytes currently held
+func (d *diskCache) size() int64 {
+	if d == nil {
+		return 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.used
+}
+
+// flushLoop persists access times and evictions periodically, new files are flushed on commit
+func (d *diskCache) flushLoop() {
+	ticker := time.NewTicker(diskFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := d.flush(); err != nil {
+			log.Printf("Failed to write disk cache index: %v", err)
+		}
+	}
+}
+
+// flush writes the index atomically when it changed since the last write
+func (d *diskCache) flush() error {
+	d.mu.Lock()
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	entries := make([]diskEntry, 0, d.lru.Len())
+	for element := d.lru.Front(); element != nil; element = element.Next() {
+		entries = append(entries, *element.Value.(*diskEntry))
+	}
+	d.dirty = false
+	d.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(d.dir, diskIndexFile), data)
+	}
+	if err != nil {
+		d.mu.Lock()
+		d.dirty = true
+		d.mu.Unlock()
+	}
+	return err
+}
+
+func (d *diskCache) objectPath(name string) string {
+	return filepath.Join(d.dir, diskObjectsDir, name)
+}
+
+// diskFileName hashes the cache key so any bucket or key can be stored as a flat file name
+func diskFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic replaces path through a temp file and rename, readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// OpenDiskObject returns the disk copy of a large object when it matches the current ETag and size.
+// The caller closes the file.
+func (r *Repository) OpenDiskObject(key, etag string, size int64) (*os.File, bool) {
+	return r.disk.open(key, etag, size)
+}
+
+// CreateDiskObject starts caching a large object on disk, nil when the disk tier is off, the object
+// doesn't fit or it is already being downloaded. The caller writes the body and calls Commit.
+func (r *Repository) CreateDiskObject(key, etag string, size int64) *DiskObjectWriter {
+	return r.disk.create(key, etag, size)
+}
+
+// DiskObjectWriter receives an object while it is streamed to the first client.
+// Write never fails so a full or broken disk doesn't interrupt the client, Commit reports the error instead.
+type DiskObjectWriter struct {
+	cache *diskCache
+	file  *os.File
+	key   string
+	etag  string
+	size  int64
+
+	written int64
+	err     error
+	done    bool
+}
+
+func (w *DiskObjectWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return len(p), nil
+	}
+	n, err := w.file.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		w.err = err
+	} else if w.written > w.size {
+		w.err = fmt.Errorf("object larger than expected: %d > %d", w.written, w.size)
+	}
+	return len(p), nil
+}
+
+// Commit publishes the object when every byte was written, otherwise the temp file is discarded
+func (w *DiskObjectWriter) Commit() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	err := w.err
+	if err == nil && w.written != w.size {
+		err = ErrDiskObjectIncomplete
+	}
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(w.file.Name())
+		w.cache.release(w.key, w.size)
+		return err
+	}
+
+	return w.cache.commit(w)
+}
@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestDiskCache(t *testing.T, dir string, budget int64) *diskCache {
+	t.Helper()
+
+	d, err := newDiskCache(dir, budget)
+	if err != nil {
+		t.Fatalf("newDiskCache: %v", err)
+	}
+	return d
+}
+
+// storeDiskObject writes a complete object of size bytes through the writer, like a first download would
+func storeDiskObject(t *testing.T, d *diskCache, key, etag string, size int64) {
+	t.Helper()
+
+	w := d.create(key, etag, size)
+	if w == nil {
+		t.Fatalf("create(%q) = nil", key)
+	}
+	w.Write(bytes.Repeat([]byte{'x'}, int(size)))
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Commit(%q): %v", key, err)
+	}
+}
+
+func readDiskObject(t *testing.T, d *diskCache, key, etag string, size int64) ([]byte, bool) {
+	t.Helper()
+
+	file, ok := d.open(key, etag, size)
+	if !ok {
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, true
+}
+
+func TestDiskCacheDisabled(t *testing.T) {
+	for _, tt := range []struct {
+		dir    string
+		budget int64
+	}{{"", 1000}, {t.TempDir(), 0}} {
+		d, err := newDiskCache(tt.dir, tt.budget)
+		if err != nil || d != nil {
+			t.Errorf("newDiskCache(%q, %d) = %v, %v, want disabled", tt.dir, tt.budget, d, err)
+		}
+	}
+
+	var d *diskCache
+	if _, ok := d.open("k", "e", 1); ok {
+		t.Error("disabled cache returned a file")
+	}
+	if d.create("k", "e", 1) != nil {
+		t.Error("disabled cache accepted a write")
+	}
+}
+
+func TestDiskCacheCommitAndOpen(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+
+	storeDiskObject(t, d, "cdn:media:a.mp4", "etag-1", 100)
+
+	data, ok := readDiskObject(t, d, "cdn:media:a.mp4", "etag-1", 100)
+	if !ok || len(data) != 100 {
+		t.Fatalf("open after commit = %d bytes, %t", len(data), ok)
+	}
+	if d.size() != 100 {
+		t.Errorf("size = %d, want 100", d.size())
+	}
+	if hits := d.stats.hits.Load(); hits != 1 {
+		t.Errorf("hits = %d, want 1", hits)
+	}
+}
+
+func TestDiskCacheStaleVersion(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+	storeDiskObject(t, d, "k", "etag-1", 100)
+
+	tests := []struct {
+		name string
+		etag string
+		size int64
+	}{
+		{"changed etag", "etag-2", 100},
+		{"changed size", "etag-1", 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storeDiskObject(t, d, "k", "etag-1", 100)
+			if _, ok := readDiskObject(t, d, "k", tt.etag, tt.size); ok {
+				t.Fatal("stale copy served")
+			}
+			// The stale copy is dropped, not only skipped
+			if _, ok := readDiskObject(t, d, "k", "etag-1", 100); ok {
+				t.Error("stale copy kept after a mismatch")
+			}
+			if d.size() != 0 {
+				t.Errorf("size = %d after dropping the stale copy, want 0", d.size())
+			}
+		})
+	}
+}
+
+func TestDiskCacheReplaceVersion(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+
+	storeDiskObject(t, d, "k", "etag-1", 100)
+	storeDiskObject(t, d, "k", "etag-2", 80)
+
+	if data, ok := readDiskObject(t, d, "k", "etag-2", 80); !ok || len(data) != 80 {
+		t.Errorf("new version = %d bytes, %t", len(data), ok)
+	}
+	if d.size() != 80 {
+		t.Errorf("size = %d, want 80", d.size())
+	}
+	if _, ok := readDiskObject(t, d, "k", "etag-1", 100); ok {
+		t.Error("old version served after replace")
+	}
+}
+
+func TestDiskCacheIncompleteWrite(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDiskCache(t, dir, 400)
+
+	tests := []struct {
+		name    string
+		written int
+	}{
+		{"short download", 60},
+		{"larger than announced", 120},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := d.create("k", "etag", 100)
+			if w == nil {
+				t.Fatal("create = nil")
+			}
+			if n, err := w.Write(bytes.Repeat([]byte{'x'}, tt.written)); n != tt.written || err != nil {
+				t.Fatalf("Write = %d, %v, writes must never fail the client", n, err)
+			}
+
+			err := w.Commit()
+			if err == nil {
+				t.Fatal("Commit succeeded")
+			}
+			if tt.written < 100 && !errors.Is(err, ErrDiskObjectIncomplete) {
+				t.Errorf("Commit = %v, want ErrDiskObjectIncomplete", err)
+			}
+
+			if _, ok := d.open("k", "etag", 100); ok {
+				t.Error("incomplete object served")
+			}
+			if d.reserved != 0 || len(d.writing) != 0 {
+				t.Errorf("reservation leaked: reserved=%d writing=%v", d.reserved, d.writing)
+			}
+			if temps, _ := os.ReadDir(filepath.Join(dir, diskTempDir)); len(temps) != 0 {
+				t.Errorf("%d temp files left behind", len(temps))
+			}
+		})
+	}
+}
+
+func TestDiskCacheSingleWriter(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+
+	w := d.create("k", "etag", 100)
+	if w == nil {
+		t.Fatal("create = nil")
+	}
+	if d.create("k", "etag", 100) != nil {
+		t.Fatal("second concurrent download of the same key accepted")
+	}
+
+	w.Write(bytes.Repeat([]byte{'x'}, 100))
+	if err := w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(); err != nil {
+		t.Errorf("second Commit = %v, want no-op", err)
+	}
+
+	if next := d.create("k", "etag-2", 100); next == nil {
+		t.Error("download refused after the first one committed")
+	} else {
+		next.Commit()
+	}
+}
+
+func TestDiskCacheSizeLimits(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+
+	for _, size := range []int64{0, -1, 101} {
+		if w := d.create("k", "etag", size); w != nil {
+			w.Commit()
+			t.Errorf("create accepted size %d with a 100 byte entry limit", size)
+		}
+	}
+}
+
+func TestDiskCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		storeDiskObject(t, d, key, "etag", 100)
+	}
+	// a becomes the most recently used, b is now the oldest
+	if _, ok := readDiskObject(t, d, "a", "etag", 100); !ok {
+		t.Fatal("a missing before eviction")
+	}
+
+	storeDiskObject(t, d, "e", "etag", 100)
+
+	want := map[string]bool{"a": true, "b": false, "c": true, "d": true, "e": true}
+	for key, cached := range want {
+		if _, ok := readDiskObject(t, d, key, "etag", 100); ok != cached {
+			t.Errorf("%s cached = %t, want %t", key, ok, cached)
+		}
+	}
+	if d.size() != 400 {
+		t.Errorf("size = %d, want 400", d.size())
+	}
+	if evictions := d.stats.evictions.Load(); evictions != 1 {
+		t.Errorf("evictions = %d, want 1", evictions)
+	}
+	if files, _ := os.ReadDir(filepath.Join(d.dir, diskObjectsDir)); len(files) != 4 {
+		t.Errorf("%d object files on disk, want 4", len(files))
+	}
+}
+
+func TestDiskCacheReservationsCountAgainstBudget(t *testing.T) {
+	d := newTestDiskCache(t, t.TempDir(), 400)
+
+	storeDiskObject(t, d, "a", "etag", 100)
+	storeDiskObject(t, d, "b", "etag", 100)
+
+	// Two downloads in flight reserve the rest of the budget, the third has to evict a cached entry
+	first := d.create("c", "etag", 100)
+	second := d.create("d", "etag", 100)
+	third := d.create("e", "etag", 100)
+	if first == nil || second == nil || third == nil {
+		t.Fatal("create = nil")
+	}
+	if _, ok := readDiskObject(t, d, "a", "etag", 100); ok {
+		t.Error("a should have been evicted to make room for the third download")
+	}
+
+	for _, w := range []*DiskObjectWriter{first, second, third} {
+		w.Commit()
+	}
+	if d.reserved != 0 {
+		t.Errorf("reserved = %d after all downloads ended, want 0", d.reserved)
+	}
+}
+
+func TestDiskCacheReload(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDiskCache(t, dir, 400)
+
+	storeDiskObject(t, d, "a", "etag-a", 100)
+	storeDiskObject(t, d, "b", "etag-b", 50)
+	readDiskObject(t, d, "a", "etag-a", 100)
+	if err := d.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leftovers of a crash: an unfinished download and a file the index never recorded
+	if err := os.WriteFile(filepath.Join(dir, diskTempDir, "object-1"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.objectPath(diskFileName("orphan")), []byte("orphan"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestDiskCache(t, dir, 400)
+
+	if data, ok := readDiskObject(t, reloaded, "a", "etag-a", 100); !ok || len(data) != 100 {
+		t.Errorf("a after reload = %d bytes, %t", len(data), ok)
+	}
+	if _, ok := readDiskObject(t, reloaded, "b", "etag-b", 50); !ok {
+		t.Error("b lost on reload")
+	}
+	if reloaded.size() != 150 {
+		t.Errorf("size after reload = %d, want 150", reloaded.size())
+	}
+	if _, err := os.Stat(d.objectPath(diskFileName("orphan"))); !os.IsNotExist(err) {
+		t.Error("orphan object file not removed on reload")
+	}
+	if temps, _ := os.ReadDir(filepath.Join(dir, diskTempDir)); len(temps) != 0 {
+		t.Errorf("%d temp files kept across restart", len(temps))
+	}
+}
+
+func TestDiskCacheReloadWithSmallerBudget(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDiskCache(t, dir, 400)
+
+	storeDiskObject(t, d, "old", "etag", 100)
+	storeDiskObject(t, d, "new", "etag", 100)
+	if err := d.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestDiskCache(t, dir, 150)
+	if _, ok := readDiskObject(t, reloaded, "old", "etag", 100); ok {
+		t.Error("least recently used entry kept over the new budget")
+	}
+	if _, ok := readDiskObject(t, reloaded, "new", "etag", 100); !ok {
+		t.Error("most recently used entry evicted")
+	}
+}
+
+func TestDiskCacheReloadDropsBrokenEntries(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDiskCache(t, dir, 400)
+
+	storeDiskObject(t, d, "truncated", "etag", 100)
+	storeDiskObject(t, d, "deleted", "etag", 100)
+	if err := os.Truncate(d.objectPath(diskFileName("truncated")), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(d.objectPath(diskFileName("deleted"))); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestDiskCache(t, dir, 400)
+	if reloaded.size() != 0 {
+		t.Errorf("size after reload = %d, want 0", reloaded.size())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, diskIndexFile), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if corrupt := newTestDiskCache(t, dir, 400); corrupt.size() != 0 {
+		t.Errorf("size with a corrupt index = %d, want 0", corrupt.size())
+	}
+}
@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"log"
+	"time"
+
 	"github.com/redis/go-redis/v9"
 	"github.com/tnqbao/gau-cdn-service/config"
 	"github.com/tnqbao/gau-cdn-service/infra"
@@ -9,6 +12,12 @@ import (
 type Repository struct {
 	envConfig *config.EnvConfig
 	cacheDb   *redis.Client
+
+	// memory is the L1 tier in front of Redis for cached bodies, nil when CACHE_SIZE is 0
+	memory     *memoryCache
+	redisStats tierStats
+	// disk holds objects too large for Redis, nil when DISK_CACHE_DIR is not set
+	disk *diskCache
 }
 
 var repository *Repository
@@ -17,10 +26,23 @@ func InitRepository(infra *infra.Infra, config *config.EnvConfig) *Repository {
 	repository = &Repository{
 		envConfig: config,
 		cacheDb:   infra.RedisClient.Client,
+		memory:    newMemoryCache(config.Limit.CacheSize, time.Duration(config.Limit.MemoryCacheTTL)*time.Second),
 	}
 	if repository.cacheDb == nil {
 		panic("database connection is nil")
 	}
+
+	disk, err := newDiskCache(config.DiskCache.Dir, config.DiskCache.MaxSize)
+	if err != nil {
+		log.Printf("Failed to open disk cache, continuing without it: %v", err)
+	}
+	repository.disk = disk
+
+	if infra.Logger != nil {
+		if err := repository.registerCacheMetrics(infra.Logger.Meter); err != nil {
+			log.Printf("Failed to register cache metrics: %v", err)
+		}
+	}
 	return repository
 }
 
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tierStats counts cache lookups of one tier
+type tierStats struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+// memoryCache is the in-process L1 tier: an LRU of cached bodies bounded by a byte budget.
+// Cached slices are shared between requests and must not be modified.
+type memoryCache struct {
+	budget   int64
+	maxEntry int64
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	used    int64
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+
+	stats tierStats
+}
+
+type memoryEntry struct {
+	key         string
+	data        []byte
+	contentType string
+	etag        string
+	expiresAt   time.Time
+}
+
+// newMemoryCache returns nil when budget is not positive, which disables the tier
+func newMemoryCache(budget int64, ttl time.Duration) *memoryCache {
+	if budget <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &memoryCache{
+		budget: budget,
+		// A single body may take a quarter of the budget, so one large file can't flush everything else
+		maxEntry: budget / 4,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// get returns the body cached for key when it holds the given version of the object
+func (m *memoryCache) get(key, etag string) ([]byte, string, bool) {
+	if m == nil {
+		return nil, "", false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		m.stats.misses.Add(1)
+		return nil, "", false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if entry.etag != etag || time.Now().After(entry.expiresAt) {
+		m.remove(element)
+		m.stats.misses.Add(1)
+		return nil, "", false
+	}
+
+	m.lru.MoveToFront(element)
+	m.stats.hits.Add(1)
+	return entry.data, entry.contentType, true
+}
+
+// set stores a body for the L1 TTL, shortened to redisTTL when that is lower
+func (m *memoryCache) set(key string, data []byte, contentType, etag string, redisTTL time.Duration) {
+	if m == nil || int64(len(data)) > m.maxEntry {
+		return
+	}
+
+	ttl := m.ttl
+	if redisTTL > 0 && redisTTL < ttl {
+		ttl = redisTTL
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+
+	entry := &memoryEntry{key: key, data: data, contentType: contentType, etag: etag, expiresAt: time.Now().Add(ttl)}
+	m.entries[key] = m.lru.PushFront(entry)
+	m.used += int64(len(data))
+
+	for m.used > m.budget {
+		m.remove(m.lru.Back())
+		m.stats.evictions.Add(1)
+	}
+}
+
+func (m *memoryCache) delete(key string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, ok := m.entries[key]; ok {
+		m.remove(element)
+	}
+}
+
+// size returns the bytes currently held
+func (m *memoryCache) size() int64 {
+	if m == nil {
+		return 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used
+}
+
+// remove drops an entry, callers hold mu
+func (m *memoryCache) remove(element *list.Element) {
+	entry := element.Value.(*memoryEntry)
+	m.lru.Remove(element)
+	delete(m.entries, entry.key)
+	m.used -= int64(len(entry.data))
+}
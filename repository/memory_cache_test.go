@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func setMemoryEntry(m *memoryCache, key string, size int) {
+	m.set(key, bytes.Repeat([]byte{'x'}, size), "text/plain", "etag", 0)
+}
+
+func TestMemoryCacheDisabled(t *testing.T) {
+	for _, tt := range []struct {
+		budget int64
+		ttl    time.Duration
+	}{{0, time.Minute}, {-1, time.Minute}, {1000, 0}} {
+		if m := newMemoryCache(tt.budget, tt.ttl); m != nil {
+			t.Errorf("newMemoryCache(%d, %s) = %v, want disabled", tt.budget, tt.ttl, m)
+		}
+	}
+
+	var m *memoryCache
+	m.set("k", []byte("data"), "text/plain", "etag", 0)
+	if _, _, ok := m.get("k", "etag"); ok {
+		t.Error("disabled cache returned an entry")
+	}
+	m.delete("k")
+	if m.size() != 0 {
+		t.Errorf("size of a disabled cache = %d", m.size())
+	}
+}
+
+func TestMemoryCacheGet(t *testing.T) {
+	m := newMemoryCache(400, time.Minute)
+	m.set("cdn:media:a.css", []byte("body"), "text/css", "etag-1", 0)
+
+	tests := []struct {
+		name string
+		key  string
+		etag string
+		want bool
+	}{
+		{"cached version", "cdn:media:a.css", "etag-1", true},
+		{"other key", "cdn:media:b.css", "etag-1", false},
+		// A mismatch drops the entry, the current version is looked up again afterwards
+		{"other version", "cdn:media:a.css", "etag-2", false},
+		{"dropped after a version mismatch", "cdn:media:a.css", "etag-1", false},
+	}
+
+	for _, tt := range tests {
+		data, ct, ok := m.get(tt.key, tt.etag)
+		if ok != tt.want {
+			t.Fatalf("%s: get(%q, %q) ok = %t, want %t", tt.name, tt.key, tt.etag, ok, tt.want)
+		}
+		if ok && (string(data) != "body" || ct != "text/css") {
+			t.Errorf("%s: get = %q, %q", tt.name, data, ct)
+		}
+	}
+
+	if hits, misses := m.stats.hits.Load(), m.stats.misses.Load(); hits != 1 || misses != 3 {
+		t.Errorf("hits=%d misses=%d, want 1 and 3", hits, misses)
+	}
+	if m.size() != 0 {
+		t.Errorf("size = %d after the entry was dropped, want 0", m.size())
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	m := newMemoryCache(400, time.Minute)
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		setMemoryEntry(m, key, 100)
+	}
+	// a becomes the most recently used, b is now the oldest
+	if _, _, ok := m.get("a", "etag"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+
+	setMemoryEntry(m, "e", 100)
+
+	want := map[string]bool{"a": true, "b": false, "c": true, "d": true, "e": true}
+	for key, cached := range want {
+		if _, _, ok := m.get(key, "etag"); ok != cached {
+			t.Errorf("%s cached = %t, want %t", key, ok, cached)
+		}
+	}
+	if m.size() != 400 {
+		t.Errorf("size = %d, want 400", m.size())
+	}
+	if evictions := m.stats.evictions.Load(); evictions != 1 {
+		t.Errorf("evictions = %d, want 1", evictions)
+	}
+}
+
+func TestMemoryCacheBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		sizes     []int
+		wantSize  int64
+		wantCount int
+	}{
+		{"entries within budget", []int{100, 100, 100}, 300, 3},
+		{"largest allowed entry", []int{100}, 100, 1},
+		// One body may take a quarter of the budget
+		{"entry over a quarter of the budget", []int{101}, 0, 0},
+		{"several evictions for one entry", []int{50, 50, 50, 50, 50, 50, 50, 50, 100}, 400, 7},
+		{"empty body", []int{0}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMemoryCache(400, time.Minute)
+			for i, size := range tt.sizes {
+				setMemoryEntry(m, string(rune('a'+i)), size)
+			}
+			if m.size() != tt.wantSize || len(m.entries) != tt.wantCount || m.lru.Len() != tt.wantCount {
+				t.Errorf("size=%d entries=%d lru=%d, want size=%d entries=%d", m.size(), len(m.entries), m.lru.Len(), tt.wantSize, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheReplaceAndDelete(t *testing.T) {
+	m := newMemoryCache(400, time.Minute)
+
+	m.set("k", bytes.Repeat([]byte{'x'}, 100), "text/plain", "etag-1", 0)
+	m.set("k", bytes.Repeat([]byte{'y'}, 60), "text/plain", "etag-2", 0)
+	if m.size() != 60 || len(m.entries) != 1 {
+		t.Errorf("size=%d entries=%d after replace, want 60 and 1", m.size(), len(m.entries))
+	}
+	if data, _, ok := m.get("k", "etag-2"); !ok || len(data) != 60 {
+		t.Errorf("replaced entry = %d bytes, %t", len(data), ok)
+	}
+
+	m.delete("k")
+	m.delete("missing")
+	if m.size() != 0 || len(m.entries) != 0 {
+		t.Errorf("size=%d entries=%d after delete, want empty", m.size(), len(m.entries))
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	m := newMemoryCache(400, time.Hour)
+
+	tests := []struct {
+		name     string
+		redisTTL time.Duration
+		want     time.Duration
+	}{
+		{"memory TTL", 0, time.Hour},
+		{"shorter Redis TTL", time.Minute, time.Minute},
+		{"longer Redis TTL", 2 * time.Hour, time.Hour},
+		{"Redis key without expiry", -1, time.Hour},
+	}
+
+	for _, tt := range tests {
+		start := time.Now()
+		m.set("k", []byte("data"), "text/plain", "etag", tt.redisTTL)
+		expiresIn := m.entries["k"].Value.(*memoryEntry).expiresAt.Sub(start)
+		if expiresIn < tt.want || expiresIn > tt.want+time.Second {
+			t.Errorf("%s: entry expires in %s, want %s", tt.name, expiresIn, tt.want)
+		}
+	}
+
+	m.entries["k"].Value.(*memoryEntry).expiresAt = time.Now().Add(-time.Second)
+	if _, _, ok := m.get("k", "etag"); ok {
+		t.Error("expired entry served")
+	}
+	if m.size() != 0 {
+		t.Errorf("size = %d after expiry, want 0", m.size())
+	}
+}
+
+func TestGetImagePromotesRedisHits(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	if err := repo.SetImage(ctx, "cdn:media:a.css", []byte("body"), "text/css", "etag-1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// The memory tier starts empty, as on a replica that didn't serve the object yet
+	repo.memory = newMemoryCache(1<<20, time.Hour)
+	if _, _, err := repo.GetImage(ctx, "cdn:media:a.css", "etag-1"); err != nil {
+		t.Fatal(err)
+	}
+	if hits := repo.redisStats.hits.Load(); hits != 1 {
+		t.Errorf("redis hits = %d, want 1", hits)
+	}
+
+	entry, ok := repo.memory.entries["cdn:media:a.css"]
+	if !ok {
+		t.Fatal("redis hit not promoted to memory")
+	}
+	// Promoted entries don't outlive the Redis copy
+	if expiresIn := time.Until(entry.Value.(*memoryEntry).expiresAt); expiresIn > time.Minute {
+		t.Errorf("promoted entry expires in %s, want at most the remaining Redis TTL", expiresIn)
+	}
+
+	if err := repo.cacheDb.FlushAll(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _, err := repo.GetImage(ctx, "cdn:media:a.css", "etag-1"); err != nil || string(data) != "body" {
+		t.Errorf("GetImage from memory = %q, %v", data, err)
+	}
+	if hits := repo.memory.stats.hits.Load(); hits != 1 {
+		t.Errorf("memory hits = %d, want 1", hits)
+	}
+}
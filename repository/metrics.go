@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	metricwrap "go.opentelemetry.io/otel/metric"
+)
+
+// registerCacheMetrics exports hit, miss and eviction counters per cache tier through OpenTelemetry
+func (r *Repository) registerCacheMetrics(meter metricwrap.Meter) error {
+	hits, err := meter.Int64ObservableCounter("cdn.cache.hits")
+	if err != nil {
+		return err
+	}
+	misses, err := meter.Int64ObservableCounter("cdn.cache.misses")
+	if err != nil {
+		return err
+	}
+	evictions, err := meter.Int64ObservableCounter("cdn.cache.evictions")
+	if err != nil {
+		return err
+	}
+	memoryBytes, err := meter.Int64ObservableGauge("cdn.cache.memory.bytes",
+		metricwrap.WithDescription("Bytes held by the in-process cache tier"))
+	if err != nil {
+		return err
+	}
+
+	diskBytes, err := meter.Int64ObservableGauge("cdn.cache.disk.bytes",
+		metricwrap.WithDescription("Bytes held by the disk cache tier"))
+	if err != nil {
+		return err
+	}
+
+	memoryTier := metricwrap.WithAttributes(attribute.String("tier", "memory"))
+	redisTier := metricwrap.WithAttributes(attribute.String("tier", "redis"))
+	diskTier := metricwrap.WithAttributes(attribute.String("tier", "disk"))
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metricwrap.Observer) error {
+		if r.memory != nil {
+			o.ObserveInt64(hits, r.memory.stats.hits.Load(), memoryTier)
+			o.ObserveInt64(misses, r.memory.stats.misses.Load(), memoryTier)
+			o.ObserveInt64(evictions, r.memory.stats.evictions.Load(), memoryTier)
+			o.ObserveInt64(memoryBytes, r.memory.size())
+		}
+		// Redis evicts on its own, only lookups are counted here
+		o.ObserveInt64(hits, r.redisStats.hits.Load(), redisTier)
+		o.ObserveInt64(misses, r.redisStats.misses.Load(), redisTier)
+		if r.disk != nil {
+			o.ObserveInt64(hits, r.disk.stats.hits.Load(), diskTier)
+			o.ObserveInt64(misses, r.disk.stats.misses.Load(), diskTier)
+			o.ObserveInt64(evictions, r.disk.stats.evictions.Load(), diskTier)
+			o.ObserveInt64(diskBytes, r.disk.size())
+		}
+		return nil
+	}, hits, misses, evictions, memoryBytes, diskBytes)
+	return err
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript implements the generic cell rate algorithm on a single "theoretical arrival time" key,
+// using the Redis clock so every replica shares one view of time. With force the cost is charged even
+// when over the limit, which lets byte counts be billed after the response has been sent.
+var gcraScript = redis.NewScript(`
+local now_parts = redis.call('TIME')
+local now = tonumber(now_parts[1]) * 1000 + tonumber(now_parts[2]) / 1000
+local emission = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local force = ARGV[4] == '1'
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + emission * cost
+local diff = now - (new_tat - tolerance)
+if diff < 0 and not force then
+	return {0, tostring(-diff), tostring(tat - now)}
+end
+
+if cost > 0 then
+	redis.call('SET', KEYS[1], tostring(new_tat), 'PX', math.ceil(new_tat - now) + 1000)
+end
+return {1, '0', tostring(new_tat - now)}
+`)
+
+// RateLimitResult is the state of one limiter after a request
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration
+	// ResetAfter is the time until the limiter is back to its full burst
+	ResetAfter time.Duration
+}
+
+// RateLimit charges cost units against a GCRA limiter of rate units per second with the given burst.
+// A zero cost only checks whether the limiter is in debt.
+func (r *Repository) RateLimit(ctx context.Context, key string, rate float64, burst, cost int64, force bool) (*RateLimitResult, error) {
+	if rate <= 0 || burst <= 0 {
+		return nil, fmt.Errorf("invalid rate limit: rate=%f, burst=%d", rate, burst)
+	}
+
+	emission := 1000 / rate // milliseconds per unit
+	tolerance := emission * float64(burst)
+	forceArg := "0"
+	if force {
+		forceArg = "1"
+	}
+
+	values, err := gcraScript.Run(ctx, r.cacheDb, []string{key}, emission, tolerance, cost, forceArg).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	retryAfter := parseMillis(values[1])
+	resetAfter := parseMillis(values[2])
+
+	remaining := int64(math.Floor((tolerance - float64(resetAfter.Milliseconds())) / emission))
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining > burst {
+		remaining = burst
+	}
+
+	return &RateLimitResult{
+		Allowed:    allowed == 1,
+		Limit:      burst,
+		Remaining:  remaining,
+		RetryAfter: retryAfter,
+		ResetAfter: resetAfter,
+	}, nil
+}
+
+func parseMillis(value interface{}) time.Duration {
+	s, _ := value.(string)
+	ms, err := strconv.ParseFloat(s, 64)
+	if err != nil || ms < 0 {
+		return 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRepository returns a repository on an in-process Redis whose clock only moves through advance
+func newTestRepository(t *testing.T) (*Repository, func(time.Duration)) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		mr.SetTime(now)
+		mr.FastForward(d)
+	}
+	return &Repository{cacheDb: client}, advance
+}
+
+func TestRateLimitGCRA(t *testing.T) {
+	type step struct {
+		advance       time.Duration
+		cost          int64
+		force         bool
+		wantAllowed   bool
+		wantRemaining int64
+		wantRetry     time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int64
+		steps []step
+	}{
+		{
+			name: "burst then steady rate",
+			rate: 10, burst: 5,
+			steps: []step{
+				{cost: 1, wantAllowed: true, wantRemaining: 4},
+				{cost: 1, wantAllowed: true, wantRemaining: 3},
+				{cost: 1, wantAllowed: true, wantRemaining: 2},
+				{cost: 1, wantAllowed: true, wantRemaining: 1},
+				{cost: 1, wantAllowed: true, wantRemaining: 0},
+				{cost: 1, wantAllowed: false, wantRemaining: 0, wantRetry: 100 * time.Millisecond},
+				{advance: 50 * time.Millisecond, cost: 1, wantAllowed: false, wantRemaining: 0, wantRetry: 50 * time.Millisecond},
+				{advance: 50 * time.Millisecond, cost: 1, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "idle limiter refills to burst",
+			rate: 10, burst: 5,
+			steps: []step{
+				{cost: 5, wantAllowed: true, wantRemaining: 0},
+				{advance: 200 * time.Millisecond, cost: 1, wantAllowed: true, wantRemaining: 1},
+				{advance: time.Hour, cost: 1, wantAllowed: true, wantRemaining: 4},
+			},
+		},
+		{
+			name: "cost larger than burst is rejected",
+			rate: 10, burst: 5,
+			steps: []step{
+				{cost: 6, wantAllowed: false, wantRemaining: 5, wantRetry: 100 * time.Millisecond},
+				{cost: 5, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "forced charge puts the limiter in debt",
+			rate: 1000, burst: 1000,
+			steps: []step{
+				{cost: 0, wantAllowed: true, wantRemaining: 1000},
+				{cost: 5000, force: true, wantAllowed: true, wantRemaining: 0},
+				{cost: 0, wantAllowed: false, wantRemaining: 0, wantRetry: 4 * time.Second},
+				{advance: 3 * time.Second, cost: 0, wantAllowed: false, wantRemaining: 0, wantRetry: time.Second},
+				{advance: time.Second, cost: 0, wantAllowed: true, wantRemaining: 0},
+				{advance: time.Second, cost: 0, wantAllowed: true, wantRemaining: 1000},
+			},
+		},
+		{
+			name: "zero cost check doesn't consume",
+			rate: 1, burst: 1,
+			steps: []step{
+				{cost: 0, wantAllowed: true, wantRemaining: 1},
+				{cost: 0, wantAllowed: true, wantRemaining: 1},
+				{cost: 1, wantAllowed: true, wantRemaining: 0},
+				{cost: 1, wantAllowed: false, wantRemaining: 0, wantRetry: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, advance := newTestRepository(t)
+			for i, s := range tt.steps {
+				advance(s.advance)
+				result, err := repo.RateLimit(context.Background(), "rl:test", tt.rate, tt.burst, s.cost, s.force)
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if result.Allowed != s.wantAllowed || result.Remaining != s.wantRemaining || result.RetryAfter != s.wantRetry {
+					t.Errorf("step %d: allowed=%t remaining=%d retry=%s, want allowed=%t remaining=%d retry=%s",
+						i, result.Allowed, result.Remaining, result.RetryAfter, s.wantAllowed, s.wantRemaining, s.wantRetry)
+				}
+				if result.Limit != tt.burst {
+					t.Errorf("step %d: limit=%d, want %d", i, result.Limit, tt.burst)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitKeysAreIndependent(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	if result, err := repo.RateLimit(ctx, "rl:a", 1, 1, 1, false); err != nil || !result.Allowed {
+		t.Fatalf("first request on a = %+v, %v", result, err)
+	}
+	if result, err := repo.RateLimit(ctx, "rl:a", 1, 1, 1, false); err != nil || result.Allowed {
+		t.Fatalf("second request on a = %+v, %v, want denied", result, err)
+	}
+	if result, err := repo.RateLimit(ctx, "rl:b", 1, 1, 1, false); err != nil || !result.Allowed {
+		t.Errorf("first request on b = %+v, %v, want allowed", result, err)
+	}
+}
+
+func TestRateLimitResetAfter(t *testing.T) {
+	repo, _ := newTestRepository(t)
+
+	result, err := repo.RateLimit(context.Background(), "rl:reset", 10, 5, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResetAfter != 300*time.Millisecond {
+		t.Errorf("ResetAfter = %s, want 300ms", result.ResetAfter)
+	}
+}
+
+func TestRateLimitRejectsInvalidRule(t *testing.T) {
+	repo, _ := newTestRepository(t)
+
+	for _, tt := range []struct {
+		rate  float64
+		burst int64
+	}{{0, 1}, {-1, 1}, {1, 0}} {
+		if _, err := repo.RateLimit(context.Background(), "rl:invalid", tt.rate, tt.burst, 1, false); err == nil {
+			t.Errorf("RateLimit(rate=%v, burst=%d) succeeded, want error", tt.rate, tt.burst)
+		}
+	}
+}
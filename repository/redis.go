@@ -2,28 +2,73 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tnqbao/gau-cdn-service/infra"
 )
 
-func (r *Repository) GetImage(ctx context.Context, key string) ([]byte, string, error) {
-	data, err := r.cacheDb.Get(ctx, key).Bytes()
+// MaxObjectMetaTTL caps how long object metadata stays cached. HEAD answers from it without asking MinIO,
+// so an overwritten object that is never fetched with GET is reported stale for at most this long.
+const MaxObjectMetaTTL = 5 * time.Minute
+
+// ErrStaleCache is returned by GetImage when the cached body belongs to another version of the object
+var ErrStaleCache = errors.New("cached body is for another object version")
+
+// GetImage returns a cached body and its content type, from the in-process tier when possible.
+// The body is only returned when it was stored for etag, a body of an overwritten object is a miss.
+// Redis hits are promoted to memory for no longer than their remaining Redis TTL.
+func (r *Repository) GetImage(ctx context.Context, key, etag string) ([]byte, string, error) {
+	if data, ct, ok := r.memory.get(key, etag); ok {
+		return data, ct, nil
+	}
+
+	pipe := r.cacheDb.Pipeline()
+	dataCmd := pipe.Get(ctx, key)
+	ctCmd := pipe.Get(ctx, key+":content-type")
+	etagCmd := pipe.Get(ctx, key+":etag")
+	ttlCmd := pipe.PTTL(ctx, key)
+	_, _ = pipe.Exec(ctx)
+
+	data, err := dataCmd.Bytes()
 	if err != nil {
+		r.redisStats.misses.Add(1)
 		return nil, "", err
 	}
-	ct, err := r.cacheDb.Get(ctx, key+":content-type").Result()
+	if etagCmd.Val() != etag {
+		// The next fetch from MinIO overwrites the stale body
+		r.redisStats.misses.Add(1)
+		return nil, "", ErrStaleCache
+	}
+	r.redisStats.hits.Add(1)
+
+	ct, err := ctCmd.Result()
 	if err != nil {
 		ct = "application/octet-stream"
 	}
+
+	// PTTL is negative for keys without expiry, the memory TTL applies alone then
+	r.memory.set(key, data, ct, etag, ttlCmd.Val())
 	return data, ct, nil
 }
 
-func (r *Repository) SetImage(ctx context.Context, key string, data []byte, contentType string) error {
+// SetImage caches a body with its content type and the ETag of the object version it was read from,
+// timeout comes from the object's cache policy
+func (r *Repository) SetImage(ctx context.Context, key string, data []byte, contentType, etag string, timeout time.Duration) error {
 	pipe := r.cacheDb.TxPipeline()
-	timeout := time.Second * time.Duration(r.envConfig.Limit.CacheTime)
 	pipe.Set(ctx, key, data, timeout)
 	pipe.Set(ctx, key+":content-type", contentType, timeout)
-	_, err := pipe.Exec(ctx)
-	return err
+	pipe.Set(ctx, key+":etag", etag, timeout)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	r.memory.set(key, data, contentType, etag, timeout)
+	return nil
 }
 
 func (r *Repository) Set(key string, value string) error {
@@ -35,9 +80,109 @@ func (r *Repository) Get(key string) (string, error) {
 }
 
 func (r *Repository) Delete(key string) error {
+	r.memory.delete(key)
 	return r.cacheDb.Del(context.Background(), key).Err()
 }
 
 func (r *Repository) GetBit(ctx context.Context, key string, offset int64) (int64, error) {
 	return r.cacheDb.GetBit(ctx, key, offset).Result()
 }
+
+// GetObjectMeta returns object metadata cached by SetObjectMeta
+func (r *Repository) GetObjectMeta(ctx context.Context, key string) (*infra.ObjectInfo, error) {
+	fields, err := r.cacheDb.HGetAll(ctx, key+":meta").Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, redis.Nil
+	}
+
+	size, err := strconv.ParseInt(fields["size"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cached size: %w", err)
+	}
+
+	info := &infra.ObjectInfo{
+		Bucket:             fields["bucket"],
+		Key:                fields["key"],
+		Size:               size,
+		ContentType:        fields["content_type"],
+		ContentEncoding:    fields["content_encoding"],
+		ETag:               fields["etag"],
+		CacheControl:       fields["cache_control"],
+		ContentLanguage:    fields["content_language"],
+		ContentDisposition: fields["content_disposition"],
+		Expires:            fields["expires"],
+	}
+	if lastModified, err := strconv.ParseInt(fields["last_modified"], 10, 64); err == nil && lastModified > 0 {
+		info.LastModified = time.Unix(lastModified, 0).UTC()
+	}
+	if userMetadata := fields["user_metadata"]; userMetadata != "" {
+		if err := json.Unmarshal([]byte(userMetadata), &info.UserMetadata); err != nil {
+			return nil, fmt.Errorf("invalid cached user metadata: %w", err)
+		}
+	}
+	return info, nil
+}
+
+// SetObjectMeta caches object metadata so HEAD requests don't need a MinIO round-trip
+func (r *Repository) SetObjectMeta(ctx context.Context, key string, info *infra.ObjectInfo) error {
+	var lastModified int64
+	if !info.LastModified.IsZero() {
+		lastModified = info.LastModified.Unix()
+	}
+	userMetadata, err := json.Marshal(info.UserMetadata)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.cacheDb.TxPipeline()
+	timeout := time.Second * time.Duration(r.envConfig.Limit.CacheTime)
+	if timeout <= 0 || timeout > MaxObjectMetaTTL {
+		timeout = MaxObjectMetaTTL
+	}
+	pipe.HSet(ctx, key+":meta", map[string]interface{}{
+		"bucket":              info.Bucket,
+		"key":                 info.Key,
+		"size":                info.Size,
+		"content_type":        info.ContentType,
+		"content_encoding":    info.ContentEncoding,
+		"etag":                info.ETag,
+		"last_modified":       lastModified,
+		"cache_control":       info.CacheControl,
+		"content_language":    info.ContentLanguage,
+		"content_disposition": info.ContentDisposition,
+		"expires":             info.Expires,
+		"user_metadata":       string(userMetadata),
+	})
+	pipe.Expire(ctx, key+":meta", timeout)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetObjectMetaETag returns the ETag of metadata cached by SetObjectMeta, redis.Nil when none is cached
+func (r *Repository) GetObjectMetaETag(ctx context.Context, key string) (string, error) {
+	return r.cacheDb.HGet(ctx, key+":meta", "etag").Result()
+}
+
+// IsMissing reports whether key was recorded as absent from the origin by SetMissing for the same version
+func (r *Repository) IsMissing(ctx context.Context, key, version string) bool {
+	recorded, err := r.cacheDb.Get(ctx, key+":missing").Result()
+	return err == nil && recorded == version
+}
+
+// SetMissing records that key does not exist in the origin, so repeated lookups skip MinIO. version identifies
+// what the miss depends on (the ETag of the object key derives from), a miss recorded for another one is ignored.
+func (r *Repository) SetMissing(ctx context.Context, key, version string) error {
+	timeout := time.Second * time.Duration(r.envConfig.Limit.CacheTime)
+	return r.cacheDb.Set(ctx, key+":missing", version, timeout).Err()
+}
+
+// VariantKey returns the cache key holding the given content encoding of key
+func VariantKey(key, encoding string) string {
+	if encoding == "" {
+		return key
+	}
+	return key + ":enc:" + encoding
+}
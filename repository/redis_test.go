@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+func TestGetImageChecksETag(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		memory bool
+	}{
+		{"redis only", false},
+		{"memory and redis", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _ := newTestRepository(t)
+			if tt.memory {
+				repo.memory = newMemoryCache(1<<20, time.Minute)
+			}
+
+			if err := repo.SetImage(ctx, "cdn:media:a.css", []byte("v1"), "text/css", "etag-1", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			data, ct, err := repo.GetImage(ctx, "cdn:media:a.css", "etag-1")
+			if err != nil || string(data) != "v1" || ct != "text/css" {
+				t.Fatalf("GetImage(etag-1) = %q, %q, %v", data, ct, err)
+			}
+
+			// The object was overwritten in MinIO, the cached body must not be served under the new ETag
+			if data, _, err := repo.GetImage(ctx, "cdn:media:a.css", "etag-2"); !errors.Is(err, ErrStaleCache) {
+				t.Fatalf("GetImage(etag-2) = %q, %v, want ErrStaleCache", data, err)
+			}
+
+			if err := repo.SetImage(ctx, "cdn:media:a.css", []byte("v2"), "text/css", "etag-2", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if data, _, err := repo.GetImage(ctx, "cdn:media:a.css", "etag-2"); err != nil || string(data) != "v2" {
+				t.Errorf("GetImage(etag-2) after refill = %q, %v", data, err)
+			}
+			if _, _, err := repo.GetImage(ctx, "cdn:media:a.css", "etag-1"); err == nil {
+				t.Error("previous version served after refill")
+			}
+		})
+	}
+}
+
+func TestGetImageWithoutStoredETag(t *testing.T) {
+	repo, _ := newTestRepository(t)
+	ctx := context.Background()
+
+	// Bodies cached before versions were recorded are treated as stale
+	if err := repo.cacheDb.Set(ctx, "cdn:media:old.css", "old", time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.GetImage(ctx, "cdn:media:old.css", "etag-1"); err == nil {
+		t.Error("body without a recorded ETag served")
+	}
+}
+
+func TestMissingIsVersioned(t *testing.T) {
+	repo, advance := newTestRepository(t)
+	repo.envConfig = &config.EnvConfig{}
+	repo.envConfig.Limit.CacheTime = 60
+	ctx := context.Background()
+
+	if repo.IsMissing(ctx, "cdn:media:app.js.br", "etag-1") {
+		t.Fatal("miss reported before one was recorded")
+	}
+	if err := repo.SetMissing(ctx, "cdn:media:app.js.br", "etag-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		want    bool
+	}{
+		{"same version", "etag-1", true},
+		// A new upload of app.js may come with its sibling, it is looked up again
+		{"new version", "etag-2", false},
+		{"no version", "", false},
+	}
+	for _, tt := range tests {
+		if got := repo.IsMissing(ctx, "cdn:media:app.js.br", tt.version); got != tt.want {
+			t.Errorf("%s: IsMissing(%q) = %t, want %t", tt.name, tt.version, got, tt.want)
+		}
+	}
+
+	advance(61 * time.Second)
+	if repo.IsMissing(ctx, "cdn:media:app.js.br", "etag-1") {
+		t.Error("miss kept after CACHE_TIME")
+	}
+}
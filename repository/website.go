@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/tnqbao/gau-cdn-service/config"
+)
+
+func websiteKey(bucket string) string {
+	return "cdn:website:" + bucket
+}
+
+// GetWebsiteConfig returns the static-site settings cached for a bucket. An empty config means the
+// bucket has no marker object.
+func (r *Repository) GetWebsiteConfig(ctx context.Context, bucket string) (*config.WebsiteConfig, error) {
+	data, err := r.cacheDb.Get(ctx, websiteKey(bucket)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var website config.WebsiteConfig
+	if err := json.Unmarshal(data, &website); err != nil {
+		return nil, err
+	}
+	return &website, nil
+}
+
+// SetWebsiteConfig caches the static-site settings of a bucket, pass an empty config to cache a missing marker
+func (r *Repository) SetWebsiteConfig(ctx context.Context, bucket string, website *config.WebsiteConfig) error {
+	data, err := json.Marshal(website)
+	if err != nil {
+		return err
+	}
+	timeout := time.Second * time.Duration(r.envConfig.Limit.CacheTime)
+	return r.cacheDb.Set(ctx, websiteKey(bucket), data, timeout).Err()
+}
@@ -1,12 +1,23 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
 	"github.com/tnqbao/gau-cdn-service/controller"
+	"github.com/tnqbao/gau-cdn-service/middlewares"
 )
 
 func SetupRouter(ctrl *controller.Controller) *gin.Engine {
-	r := gin.Default()
+	// gin.Default() without its logger, the access log masks credentials and signatures in query strings
+	r := gin.New()
+	r.Use(middlewares.Logger(), gin.Recovery())
+
+	// Forwarding headers are only trusted from the configured proxies (none by default)
+	if err := r.SetTrustedProxies(ctrl.Config.EnvConfig.Network.TrustedProxies); err != nil {
+		log.Printf("Invalid TRUSTED_PROXIES, trusting no proxy: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -17,7 +28,23 @@ func SetupRouter(ctrl *controller.Controller) *gin.Engine {
 	// - /:bucket/filename.ext
 	// - /:bucket/folder/filename.ext
 	// - /:bucket/folder1/folder2/filename.ext
-	r.GET("/:bucket/*path", ctrl.GetFile)
+	// Hosts listed in VHOST_CONFIG map the whole path into their bucket instead
+	cdn := r.Group("/",
+		middlewares.VirtualHostMiddleware(ctrl.Config),
+		middlewares.RewriteMiddleware(ctrl.Config),
+		middlewares.BucketMiddleware(ctrl.Config),
+		middlewares.IPFilterMiddleware(ctrl.Config),
+		middlewares.CORSMiddleware(ctrl.Config),
+		middlewares.SignedURLMiddleware(ctrl.Config),
+		middlewares.HotlinkMiddleware(ctrl.Config, ctrl.ServeHotlinkPlaceholder),
+		middlewares.AuthMiddleware(ctrl.Config),
+		middlewares.RateLimitMiddleware(ctrl.Config, ctrl.Repository),
+	)
+	for _, pattern := range []string{"/", "/:bucket", "/:bucket/*path"} {
+		cdn.GET(pattern, ctrl.GetFile)
+		cdn.HEAD(pattern, ctrl.HeadFile)
+		cdn.OPTIONS(pattern, ctrl.OptionsFile)
+	}
 
 	return r
 }
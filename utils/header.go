@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddVary appends field to the Vary response header unless it is already listed
+func AddVary(c *gin.Context, field string) {
+	header := c.Writer.Header()
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
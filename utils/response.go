@@ -53,3 +53,10 @@ func JSON403(c *gin.Context, err string) {
 		"status": 403,
 	})
 }
+
+func JSON429(c *gin.Context, err string) {
+	c.JSON(429, gin.H{
+		"error":  err,
+		"status": 429,
+	})
+}